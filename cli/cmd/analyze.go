@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	why     string
+	topMods int
+)
+
+var analyzeCommand = &cobra.Command{
+	Use:   "analyze [file]",
+	Short: "Print a size breakdown of a bundle",
+	Long: `Print the size of each package and module in the bundled output.
+The argument can be a metafile written by "ags bundle --metafile",
+an entry file or a project directory.`,
+	Example: `  ags analyze --minify
+  ags analyze meta.json --why gnim/dist/jsx`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := targetDir
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		if strings.HasSuffix(path, ".json") {
+			analyze(lib.ParseMetafile(string(lib.ReadFile(path))), lib.Cwd())
+			return
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			lib.Err(err)
+		}
+
+		info, err := os.Stat(abs)
+		if err != nil {
+			lib.Err(err)
+		}
+
+		infile, dir := abs, filepath.Dir(abs)
+		if info.IsDir() {
+			infile, dir = getAppEntry(abs), abs
+		}
+
+		result := lib.Bundle(lib.BundleOpts{
+			Infile:           infile,
+			WorkingDirectory: dir,
+			Defines:          defines,
+			Alias:            alias,
+			GtkVersion:       inferGtkVersion(infile, dir),
+			Minify:           minify,
+			Sourcemap:        "none",
+			LegalComments:    "none",
+			Metafile:         true,
+			SkipTypelibCheck: true,
+		})
+
+		analyze(lib.ParseMetafile(result.Metafile), dir)
+	},
+}
+
+func init() {
+	f := analyzeCommand.Flags()
+	f.StringVarP(&targetDir, "directory", "d", defaultConfigDir(), "directory of the project")
+	f.StringArrayVar(&defines, "define", []string{}, "replace global identifiers with constant expressions")
+	f.StringArrayVar(&alias, "alias", []string{}, "alias packages")
+	f.UintVarP(&gtkVersion, "gtk", "g", 0, "gtk version")
+	f.BoolVar(&minify, "minify", false, "measure the minified output")
+	f.StringVar(&why, "why", "", "explain why modules matching this substring are included")
+	f.IntVarP(&topMods, "top", "n", 20, "number of modules to list, 0 lists all")
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MiB", float64(n)/1024/1024)
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KiB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// the package a module belongs to, modules outside of any package belong to the project
+func modulePackage(module, dir string) string {
+	// modules loaded by plugins are prefixed with their namespace e.g "sass:style.scss"
+	if ns, path, ok := strings.Cut(module, ":"); ok && !strings.Contains(ns, "/") {
+		module = path
+	}
+
+	if i := strings.LastIndex(module, "node_modules/"); i != -1 {
+		parts := strings.Split(module[i+len("node_modules/"):], "/")
+		if strings.HasPrefix(parts[0], "@") && len(parts) > 1 {
+			return parts[0] + "/" + parts[1]
+		}
+		return parts[0]
+	}
+
+	if !filepath.IsAbs(module) {
+		module = filepath.Join(dir, module)
+	}
+
+	if env.AgsJsPackage != "" && strings.HasPrefix(module, env.AgsJsPackage+"/") {
+		return "ags"
+	}
+
+	return "(project)"
+}
+
+type sizeEntry struct {
+	Name  string
+	Bytes int
+}
+
+func sortedSizes(sizes map[string]int) []sizeEntry {
+	entries := make([]sizeEntry, 0, len(sizes))
+	for name, bytes := range sizes {
+		entries = append(entries, sizeEntry{name, bytes})
+	}
+	slices.SortFunc(entries, func(a, b sizeEntry) int {
+		if a.Bytes != b.Bytes {
+			return b.Bytes - a.Bytes
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries
+}
+
+func printSizes(title string, entries []sizeEntry, total, limit int) {
+	fmt.Println(lib.Blue(title))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for i, e := range entries {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(w, "\t\t%s\t\n", fmt.Sprintf("... %d more", len(entries)-limit))
+			break
+		}
+		percent := 0.0
+		if total > 0 {
+			percent = float64(e.Bytes) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "%s\t%.1f%%\t %s\n", formatBytes(e.Bytes), percent, e.Name)
+	}
+	w.Flush()
+}
+
+func analyze(meta lib.Metafile, dir string) {
+	modules := map[string]int{}
+	packages := map[string]int{}
+	var entries []string
+	total := 0
+
+	for _, output := range meta.Outputs {
+		if output.EntryPoint != "" {
+			entries = append(entries, output.EntryPoint)
+		}
+		for module, input := range output.Inputs {
+			modules[module] += input.BytesInOutput
+			packages[modulePackage(module, dir)] += input.BytesInOutput
+			total += input.BytesInOutput
+		}
+	}
+
+	if why != "" {
+		explain(meta, entries, why)
+		return
+	}
+
+	printSizes("packages", sortedSizes(packages), total, 0)
+	fmt.Println()
+	printSizes("modules", sortedSizes(modules), total, topMods)
+	fmt.Printf("\n%s %s\n", lib.Blue("total"), formatBytes(total))
+}
+
+// prints the shortest import chain from an entry point to each module matching pattern
+func explain(meta lib.Metafile, entries []string, pattern string) {
+	parent := map[string]string{}
+	queue := slices.Clone(entries)
+	seen := map[string]bool{}
+	for _, e := range entries {
+		seen[e] = true
+	}
+
+	var order []string
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		order = append(order, module)
+
+		for _, imp := range meta.Inputs[module].Imports {
+			if imp.External || seen[imp.Path] {
+				continue
+			}
+			seen[imp.Path] = true
+			parent[imp.Path] = module
+			queue = append(queue, imp.Path)
+		}
+	}
+
+	found := false
+	for _, module := range order {
+		if !strings.Contains(module, pattern) {
+			continue
+		}
+		found = true
+
+		chain := []string{module}
+		for p, ok := parent[module]; ok; p, ok = parent[p] {
+			chain = append(chain, p)
+		}
+		slices.Reverse(chain)
+
+		fmt.Println(lib.Cyan(module))
+		for i, m := range chain {
+			fmt.Printf("%s%s\n", strings.Repeat("  ", i+1), m)
+		}
+	}
+
+	if !found {
+		lib.Err(`no module matches "` + pattern + `"`)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"ags/lib"
 	"bytes"
 	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strings"
 	"text/template"
 
+	"github.com/evanw/esbuild/pkg/api"
 	"github.com/spf13/cobra"
 )
 
@@ -18,25 +21,134 @@ file="${XDG_RUNTIME_DIR:-/tmp}/{{.Hash}}-ags.js"
 {{.Cat}} <<EOF | {{.Base64}} --decode > $file
 {{.JsCode}}
 EOF
-
+{{if .Schemas}}
+schemas="${XDG_RUNTIME_DIR:-/tmp}/{{.Hash}}-ags-schemas"
+{{.Mkdir}} -p $schemas
+{{.Cat}} <<EOF | {{.Base64}} --decode > $schemas/gschemas.compiled
+{{.Schemas}}
+EOF
+export GSETTINGS_SCHEMA_DIR="$schemas${GSETTINGS_SCHEMA_DIR:+:$GSETTINGS_SCHEMA_DIR}"
+{{end}}
+{{- if .Fonts}}
+fonts="${XDG_RUNTIME_DIR:-/tmp}/{{.Hash}}-ags-fonts"
+{{.Mkdir}} -p $fonts
+{{- range .Fonts}}
+{{$.Cat}} <<EOF | {{$.Base64}} --decode > $fonts/{{.Name}}
+{{.Data}}
+EOF
+{{- end}}
+{{.Cat}} <<EOF > $fonts/fonts.conf
+{{.Fontconfig}}
+EOF
+export FONTCONFIG_FILE=$fonts/fonts.conf
+{{end}}
+{{- if .Icons}}
+export AGS_ICONS_DIR="${XDG_RUNTIME_DIR:-/tmp}/{{.Hash}}-ags-icons"
+{{- range .IconDirs}}
+{{$.Mkdir}} -p $AGS_ICONS_DIR/{{.}}
+{{- end}}
+{{- range .Icons}}
+{{$.Cat}} <<EOF | {{$.Base64}} --decode > $AGS_ICONS_DIR/{{.Name}}
+{{.Data}}
+EOF
+{{- end}}
+{{end}}
+{{- if .Locales}}
+export AGS_LOCALE_DIR="${XDG_RUNTIME_DIR:-/tmp}/{{.Hash}}-ags-locale"
+{{- range .Locales}}
+{{$.Mkdir}} -p $AGS_LOCALE_DIR/{{.Lang}}/LC_MESSAGES
+{{$.Cat}} <<EOF | {{$.Base64}} --decode > $AGS_LOCALE_DIR/{{.Lang}}/LC_MESSAGES/{{$.TextDomain}}.mo
+{{.Mo}}
+EOF
+{{- end}}
+{{end}}
 LD_PRELOAD="{{.Gtk4LayerShell}}" {{.Gjs}} -m $file $@`
 
+// starts a program of a workspace which is installed into {{.Libdir}}
+var bashLauncher = `#!{{.Bash}}
+{{- if .Schemas}}
+export GSETTINGS_SCHEMA_DIR="{{.Libdir}}/schemas${GSETTINGS_SCHEMA_DIR:+:$GSETTINGS_SCHEMA_DIR}"
+{{- end}}
+{{- if .Locales}}
+export AGS_LOCALE_DIR="{{.Libdir}}/locale"
+{{- end}}
+{{- if .Icons}}
+export AGS_ICONS_DIR="{{.Libdir}}/icons"
+{{- end}}
+{{- if .Fonts}}
+export FONTCONFIG_FILE="{{.Libdir}}/fonts/fonts.conf"
+{{- end}}
+AGS_INSTANCE_NAME="{{.Instance}}" LD_PRELOAD="{{.Gtk4LayerShell}}" {{.Gjs}} -m "{{.Libdir}}/{{.Name}}.js" $@`
+
+type LauncherArgs struct {
+	Bash           string
+	Name           string
+	Instance       string
+	Libdir         string
+	Gtk4LayerShell string
+	Gjs            string
+	Schemas        bool
+	Locales        bool
+	Fonts          bool
+	Icons          bool
+}
+
 type WrapperArgs struct {
 	Bash           string
 	Hash           string
 	JsCode         string
 	Cat            string
 	Base64         string
+	Mkdir          string
 	Gtk4LayerShell string
 	Gjs            string
+	Schemas        string // base64 encoded gschemas.compiled
+	TextDomain     string
+	Locales        []LocaleArgs
+	Fonts          []FileArgs
+	Fontconfig     string
+	IconDirs       []string
+	Icons          []FileArgs
 }
 
+// a file shipped in the wrapper, Name is relative to the directory it is extracted to
+type FileArgs struct {
+	Name string
+	Data string // base64 encoded
+}
+
+type LocaleArgs struct {
+	Lang string
+	Mo   string // base64 encoded
+}
+
+var (
+	minify        bool
+	sourcemap     string
+	drop          []string
+	legalComments string
+	metafile      string
+	outdir        string
+	libdir        string
+)
+
 var bundleCommand = &cobra.Command{
-	Use:     "bundle [entryfile] [outfile]",
-	Short:   "Bundle an app",
-	Example: `  bundle app.ts my-shell -d "DATADIR='/usr/share/my-shell'"`,
-	Args:    cobra.ExactArgs(2),
-	Run:     bundle,
+	Use:   "bundle [entryfile] [outfile]",
+	Short: "Bundle an app",
+	Long: `Bundle an app into a single executable script.
+
+With --outdir every entry file is bundled into its own launcher script
+and the modules they share are only emitted once. Without entry files
+the programs are read from ags.json in the root directory.`,
+	Example: `  bundle app.ts my-shell -d "DATADIR='/usr/share/my-shell'"
+  bundle bar launcher --outdir build --libdir /usr/share/my-shell`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if outdir != "" {
+			return cobra.ArbitraryArgs(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	Run: bundle,
 }
 
 func init() {
@@ -45,30 +157,73 @@ func init() {
 	f.StringArrayVarP(&defines, "define", "d", []string{}, "replace global identifiers with constant expressions")
 	f.StringArrayVar(&alias, "alias", []string{}, "alias packages")
 	f.UintVarP(&gtkVersion, "gtk", "g", 0, "gtk version")
+	f.BoolVar(&skipTypelibCheck, "skip-typelib-check", false, "do not check if imported typelibs are installed")
+	f.BoolVar(&strictCss, "strict-css", false, "fail on css which gtk does not support instead of warning")
+	f.StringArrayVar(&fontDirs, "fonts", []string{}, "directory of fonts to ship with the bundle, can be repeated")
+	envFlags(bundleCommand)
+	f.BoolVar(&inlineEnv, "inline-env", false, "allow --env-file to bake its values into the bundle")
+	f.BoolVar(&minify, "minify", false, "minify the bundled code")
+	f.StringVar(&sourcemap, "sourcemap", "inline", "source map mode: inline, external or none")
+	f.StringSliceVar(&drop, "drop", []string{}, "drop console calls and/or debugger statements: console, debugger")
+	f.StringVar(&legalComments, "legal-comments", "eof", "legal comment mode: none, inline, eof or external")
+	f.StringVar(&metafile, "metafile", "", "write the esbuild metafile to this path, see ags analyze")
+	f.StringVarP(&outdir, "outdir", "o", "", "bundle multiple programs into this directory")
+	f.StringVar(&libdir, "libdir", "", "directory the modules of --outdir are installed to, defaults to <outdir>/js")
+	f.StringArrayVarP(&programs, "program", "p", []string{}, "only bundle this program of the workspace, can be repeated")
 }
 
-func inferGtkVersion(entryfile string) uint {
-	content, err := os.ReadFile(entryfile)
-	if err != nil {
-		lib.Err(err)
+// finds the output file ending with suffix, without an outfile
+// esbuild names the bundle "<stdout>" which is found with ".js"
+func outputFile(result api.BuildResult, suffix string) (api.OutputFile, bool) {
+	for _, file := range result.OutputFiles {
+		if strings.HasSuffix(file.Path, suffix) || suffix == ".js" && file.Path == "<stdout>" {
+			return file, true
+		}
 	}
+	return api.OutputFile{}, false
+}
 
-	gtk3 := `(?:from|import)\s+["'](?:gi://Gtk\?version=3\.0|ags/gtk3/app)["']`
-	if regexp.MustCompile(gtk3).Match(content) {
-		return 3
+// infers the gtk version from the module graph of the entry file,
+// an explicit --gtk flag has to agree with the modules
+func inferGtkVersion(entryfile, rootdir string) uint {
+	inferred := lib.InferGtkVersion(lib.BundleOpts{
+		Infile:           entryfile,
+		Defines:          defines,
+		Alias:            alias,
+		WorkingDirectory: rootdir,
+	})
+
+	if gtkVersion != 0 {
+		if inferred != 0 && inferred != gtkVersion {
+			lib.Err(fmt.Sprintf("--gtk %d conflicts with the modules which import Gtk%d", gtkVersion, inferred))
+		}
+		return gtkVersion
 	}
 
-	gtk4 := `(?:from|import)\s+["'](?:gi://Gtk\?version=4\.0|ags/gtk4/app)["']`
-	if regexp.MustCompile(gtk4).Match(content) {
-		return 4
+	if inferred == 0 {
+		lib.Err("Failed to infer Gtk version from the imported modules.\n" +
+			lib.Cyan("tip: ") + "specify it with the --gtk flag")
 	}
 
-	lib.Err("Failed to infer Gtk version from entry file.\n" +
-		lib.Cyan("tip: ") + "specify it with the --gtk flag")
-	return 0
+	return inferred
 }
 
 func bundle(cmd *cobra.Command, args []string) {
+	if len(envFiles) > 0 && !inlineEnv {
+		lib.Err("--env-file bakes the values of the variables into the bundle\n" +
+			lib.Cyan("tip: ") + "pass --inline-env to confirm")
+	}
+	defines = withEnvDefines(defines)
+
+	if outdir != "" {
+		root := workingDir
+		if root == "" {
+			root = lib.Cwd()
+		}
+		bundleWorkspace(workspacePrograms(root, args, programs))
+		return
+	}
+
 	path, err := filepath.Abs(args[0])
 	if err != nil {
 		lib.Err(err)
@@ -89,8 +244,29 @@ func bundle(cmd *cobra.Command, args []string) {
 		infile = getAppEntry(path)
 	}
 
-	if gtkVersion == 0 {
-		gtkVersion = inferGtkVersion(infile)
+	gtkVersion = inferGtkVersion(infile, workingDir)
+
+	root := workingDir
+	if root == "" {
+		root = filepath.Dir(infile)
+	}
+
+	schemadir, cleanupSchemas := lib.TempDir("ags-schemas-")
+	defer cleanupSchemas()
+
+	var schemas string
+	if compileSchemas(root, schemadir) {
+		schemas = base64.StdEncoding.EncodeToString(lib.ReadFile(filepath.Join(schemadir, "gschemas.compiled")))
+	}
+
+	var textDomain string
+	var locales []LocaleArgs
+	if catalogs := loadCatalogs(root); len(catalogs) > 0 {
+		textDomain = gettextDomain(root)
+		for lang, po := range catalogs {
+			locales = append(locales, LocaleArgs{lang, base64.StdEncoding.EncodeToString(po.Mo())})
+		}
+		slices.SortFunc(locales, func(a, b LocaleArgs) int { return strings.Compare(a.Lang, b.Lang) })
 	}
 
 	result := lib.Bundle(lib.BundleOpts{
@@ -100,13 +276,37 @@ func bundle(cmd *cobra.Command, args []string) {
 		Defines:          defines,
 		GtkVersion:       gtkVersion,
 		WorkingDirectory: workingDir,
+		SkipTypelibCheck: skipTypelibCheck,
+		StrictCss:        strictCss,
+		TextDomain:       textDomain,
+		Minify:           minify,
+		Sourcemap:        sourcemap,
+		LegalComments:    legalComments,
+		Drop:             drop,
+		Metafile:         true,
+		OutputPath:       outfile + ".js",
 	})
 
-	if len(result.OutputFiles) != 1 {
+	js, ok := outputFile(result, ".js")
+	if !ok {
 		lib.Err("internal error")
 	}
 
-	jscode := result.OutputFiles[0].Contents
+	jscode := js.Contents
+
+	// the script is extracted into $XDG_RUNTIME_DIR so the map is referenced by absolute path
+	if sourcemap, ok := outputFile(result, ".js.map"); ok {
+		lib.WriteFile(outfile+".js.map", string(sourcemap.Contents))
+		jscode = append(jscode, []byte("//# sourceMappingURL=file://"+outfile+".js.map\n")...)
+	}
+
+	if legal, ok := outputFile(result, ".LEGAL.txt"); ok {
+		lib.WriteFile(outfile+".LEGAL.txt", string(legal.Contents))
+	}
+
+	if metafile != "" {
+		lib.WriteFile(metafile, result.Metafile)
+	}
 
 	wrapperArgs := WrapperArgs{
 		Hash:           base64.RawURLEncoding.EncodeToString(jscode)[:6],
@@ -116,6 +316,27 @@ func bundle(cmd *cobra.Command, args []string) {
 		Gjs:            env.Gjs,
 		Cat:            env.Cat,
 		Base64:         env.Base64,
+		Mkdir:          env.Mkdir,
+		Schemas:        schemas,
+		TextDomain:     textDomain,
+		Locales:        locales,
+		Fontconfig:     lib.Fontconfig("$fonts", "${FONTCONFIG_FILE:-/etc/fonts/fonts.conf}"),
+	}
+
+	fonts := bundleFonts(metafileDir(workingDir), result.Metafile)
+	for i, name := range fontFileNames(fonts) {
+		wrapperArgs.Fonts = append(wrapperArgs.Fonts, FileArgs{name, base64.StdEncoding.EncodeToString(lib.ReadFile(fonts[i]))})
+	}
+
+	iconsdir, cleanupIcons := lib.TempDir("ags-icons-")
+	defer cleanupIcons()
+
+	for _, icon := range buildIcons(root, iconsdir) {
+		if dir := filepath.Dir(icon); !slices.Contains(wrapperArgs.IconDirs, dir) {
+			wrapperArgs.IconDirs = append(wrapperArgs.IconDirs, dir)
+		}
+		data := base64.StdEncoding.EncodeToString(lib.ReadFile(filepath.Join(iconsdir, icon)))
+		wrapperArgs.Icons = append(wrapperArgs.Icons, FileArgs{filepath.ToSlash(icon), data})
 	}
 
 	if gtkVersion == 3 {
@@ -137,3 +358,101 @@ func bundle(cmd *cobra.Command, args []string) {
 		lib.Err(err)
 	}
 }
+
+// bundles the programs into outdir/js and writes a launcher for each of them
+func bundleWorkspace(progs []program) {
+	if len(progs) == 0 {
+		lib.Err("no programs to bundle")
+	}
+
+	out, err := filepath.Abs(outdir)
+	if err != nil {
+		lib.Err(err)
+	}
+
+	jsdir := filepath.Join(out, "js")
+	lib.Rm(jsdir)
+
+	root := workingDir
+	if root == "" {
+		root = lib.Cwd()
+	}
+	schemas := compileSchemas(root, filepath.Join(jsdir, "schemas"))
+	textDomain := compileLocales(root, filepath.Join(jsdir, "locale"))
+	icons := len(buildIcons(root, filepath.Join(jsdir, "icons"))) > 0
+
+	var metafiles []string
+	groups := programsByGtk(progs)
+	for gtk, group := range groups {
+		result := lib.Bundle(lib.BundleOpts{
+			Entries:          bundleEntries(group),
+			Outdir:           jsdir,
+			Alias:            alias,
+			Defines:          defines,
+			GtkVersion:       gtk,
+			WorkingDirectory: workingDir,
+			SkipTypelibCheck: skipTypelibCheck,
+			StrictCss:        strictCss,
+			TextDomain:       textDomain,
+			Minify:           minify,
+			Sourcemap:        sourcemap,
+			LegalComments:    legalComments,
+			Drop:             drop,
+			Metafile:         true,
+		})
+		metafiles = append(metafiles, result.Metafile)
+
+		if metafile != "" {
+			path := metafile
+			if len(groups) > 1 {
+				ext := filepath.Ext(metafile)
+				path = fmt.Sprintf("%s-gtk%d%s", strings.TrimSuffix(metafile, ext), gtk, ext)
+			}
+			lib.WriteFile(path, result.Metafile)
+		}
+	}
+
+	dir := libdir
+	if dir == "" {
+		dir = jsdir
+	}
+
+	// the include is static as the configuration is installed with the programs
+	fonts := bundleFonts(metafileDir(workingDir), metafiles...)
+	if len(fonts) > 0 {
+		installFonts(fonts, filepath.Join(jsdir, "fonts"), filepath.Join(dir, "fonts"), "/etc/fonts/fonts.conf")
+	}
+
+	tmpl, err := template.New("bashLauncher").Parse(bashLauncher)
+	if err != nil {
+		lib.Err(err)
+	}
+
+	for _, p := range progs {
+		args := LauncherArgs{
+			Bash:           env.Bash,
+			Name:           p.Name,
+			Instance:       p.Instance,
+			Libdir:         dir,
+			Gtk4LayerShell: env.Gtk4LayerShell,
+			Gjs:            env.Gjs,
+			Schemas:        schemas,
+			Locales:        textDomain != "",
+			Fonts:          len(fonts) > 0,
+			Icons:          icons,
+		}
+
+		if p.Gtk == 3 {
+			args.Gtk4LayerShell = ""
+		}
+
+		var script bytes.Buffer
+		if err := tmpl.Execute(&script, args); err != nil {
+			lib.Err(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(out, p.Name), script.Bytes(), 0755); err != nil {
+			lib.Err(err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var depsCommand = &cobra.Command{
+	Use:   "deps",
+	Short: "List typelibs needed at runtime",
+	Long: `List the typelibs a project needs at runtime including their dependencies
+and the packages which installed them where discoverable.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		deps(targetDir)
+	},
+}
+
+func init() {
+	f := depsCommand.Flags()
+	f.StringVarP(&targetDir, "directory", "d", defaultConfigDir(), "directory of the project")
+}
+
+type typelibDep struct {
+	Name     string
+	Path     string
+	Owner    string
+	Importer string
+}
+
+func deps(dir string) {
+	result := lib.Bundle(lib.BundleOpts{
+		Infile:           getAppEntry(dir),
+		WorkingDirectory: dir,
+		Metafile:         true,
+		SkipTypelibCheck: true,
+	})
+
+	typelibs := lib.FindTypelibs()
+	var found []typelibDep
+	var missing []typelibDep
+
+	seen := map[string]bool{}
+	var add func(namespace, version, importer string, optional bool)
+	add = func(namespace, version, importer string, optional bool) {
+		typelib, ok := lib.FindTypelib(typelibs, namespace, version)
+		if !ok && optional {
+			return
+		}
+		if !ok {
+			name := namespace
+			if version != "" {
+				name += "-" + version
+			}
+			if !seen[name] {
+				seen[name] = true
+				missing = append(missing, typelibDep{Name: name, Importer: importer})
+			}
+			return
+		}
+
+		if seen[typelib.Name()] {
+			return
+		}
+		seen[typelib.Name()] = true
+
+		found = append(found, typelibDep{
+			Name:  typelib.Name(),
+			Path:  typelib.Path,
+			Owner: lib.TypelibOwner(typelib.Path),
+		})
+
+		// typelibs load their dependencies implicitly
+		for _, dep := range lib.TypelibDependencies(typelib.Path) {
+			ns, ver, _ := strings.Cut(dep, "-")
+			add(ns, ver, typelib.Name(), false)
+		}
+	}
+
+	for _, imp := range lib.ParseMetafile(result.Metafile).GiImports() {
+		// dynamic imports are optional, only listed when installed
+		add(imp.Namespace, imp.Version, imp.Importer, imp.Dynamic)
+	}
+
+	slices.SortFunc(found, func(a, b typelibDep) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, dep := range found {
+		owner := dep.Owner
+		if owner == "" {
+			owner = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", dep.Name, lib.Cyan(owner), dep.Path)
+	}
+	w.Flush()
+
+	for _, dep := range missing {
+		fmt.Fprintf(os.Stderr, "%s %s imported by %s\n", lib.Red("missing"), dep.Name, dep.Importer)
+	}
+
+	if len(missing) > 0 {
+		lib.Exit(1)
+	}
+}
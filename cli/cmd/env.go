@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	envFiles  []string
+	envPrefix string
+	inlineEnv bool
+)
+
+func envFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringArrayVar(&envFiles, "env-file", []string{}, "expose the variables of this .env file as import.meta.env and process.env, can be repeated")
+	f.StringVar(&envPrefix, "env-prefix", "PUBLIC_", "only expose variables of --env-file starting with this prefix")
+}
+
+// variables of the --env-file files which start with the prefix, every file is
+// overridden by <file>.local next to it and the environment overrides both
+func loadEnv() map[string]string {
+	vars := map[string]string{}
+	for _, file := range envFiles {
+		for _, path := range []string{file, file + ".local"} {
+			if path != file && !lib.FileExists(path) {
+				continue
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				lib.Err(err)
+			}
+
+			parsed, err := lib.ParseDotenv(string(content))
+			if err != nil {
+				lib.Err(fmt.Sprintf("%s:%v", path, err))
+			}
+
+			for key, value := range parsed {
+				if strings.HasPrefix(key, envPrefix) {
+					vars[key] = value
+				}
+			}
+		}
+	}
+
+	for key := range vars {
+		if value, ok := os.LookupEnv(key); ok {
+			vars[key] = value
+		}
+	}
+
+	return vars
+}
+
+// defines of the variables of the --env-file files, before the
+// --define flags so that those take precedence
+func withEnvDefines(defines []string) []string {
+	if len(envFiles) == 0 {
+		return defines
+	}
+	return append(lib.EnvDefines(loadEnv()), defines...)
+}
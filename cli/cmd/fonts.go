@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+var fontDirs []string
+
+// font files imported by the bundles and the ones in the --fonts directories,
+// paths in the metafiles are relative to workdir
+func bundleFonts(workdir string, metafiles ...string) []string {
+	var fonts []string
+	add := func(file string) {
+		if !slices.Contains(fonts, file) {
+			fonts = append(fonts, file)
+		}
+	}
+
+	for _, meta := range metafiles {
+		for _, font := range lib.ParseMetafile(meta).Fonts() {
+			add(filepath.Join(workdir, font))
+		}
+	}
+
+	for _, dir := range fontDirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			lib.Err(err)
+		}
+		if !lib.FileExists(abs) {
+			lib.Err("no such font directory: " + dir)
+		}
+		for _, font := range lib.FindFonts(abs) {
+			add(font)
+		}
+	}
+
+	return fonts
+}
+
+// directory the paths in the metafile of a bundle are relative to
+func metafileDir(workingDir string) string {
+	if workingDir == "" {
+		return lib.Cwd()
+	}
+	return projectRoot(workingDir)
+}
+
+var unsafeFileName = regexp.MustCompile(`[^\w.-]+`)
+
+// names of the fonts in the shipped font directory, which is flat
+func fontFileNames(fonts []string) []string {
+	names := make([]string, len(fonts))
+	for i, font := range fonts {
+		name := unsafeFileName.ReplaceAllString(filepath.Base(font), "-")
+		for n := 2; slices.Contains(names[:i], name); n++ {
+			name = fmt.Sprintf("%d-%s", n, unsafeFileName.ReplaceAllString(filepath.Base(font), "-"))
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// the configuration fontconfig loads when FONTCONFIG_FILE is not set
+func fontconfigInclude() string {
+	if file := os.Getenv("FONTCONFIG_FILE"); file != "" {
+		return file
+	}
+	return "/etc/fonts/fonts.conf"
+}
+
+// copies the fonts into dir next to a fonts.conf which adds them to the fonts of include,
+// fontdir is where the directory is found at runtime
+func installFonts(fonts []string, dir, fontdir, include string) {
+	lib.Rm(dir)
+	lib.Mkdir(dir)
+	for i, name := range fontFileNames(fonts) {
+		lib.Copy(fonts[i], filepath.Join(dir, name))
+	}
+	lib.WriteFile(filepath.Join(dir, "fonts.conf"), lib.Fontconfig(fontdir, include))
+}
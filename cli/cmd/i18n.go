@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// directory of the catalogs in the root of a project
+const poDir = "po"
+
+var (
+	domain    string
+	languages []string
+)
+
+var i18nCommand = &cobra.Command{
+	Use:   "i18n",
+	Short: "Manage translations",
+	Long: `Manage the gettext translations of a project.
+
+Messages are extracted from _(), gettext(), ngettext(), C_() and pgettext()
+calls of the modules the app imports into po/<domain>.pot. Translations are
+kept in po/<lang>.po and bundled with the app.`,
+}
+
+var i18nExtractCommand = &cobra.Command{
+	Use:   "extract",
+	Short: "Extract translatable messages into a template",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		root := projectRoot(targetDir)
+		extractMessages(root, gettextDomain(root))
+	},
+}
+
+var i18nUpdateCommand = &cobra.Command{
+	Use:   "update",
+	Short: "Merge the extracted messages into the translations",
+	Example: `  ags i18n update
+  ags i18n update --add de --add hu`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		root := projectRoot(targetDir)
+		updateCatalogs(root, extractMessages(root, gettextDomain(root)))
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{i18nExtractCommand, i18nUpdateCommand} {
+		f := cmd.Flags()
+		f.StringVarP(&targetDir, "directory", "d", defaultConfigDir(), "directory of the project")
+		f.StringVar(&domain, "domain", "", "gettext domain, defaults to the name of the project directory")
+		f.StringArrayVar(&defines, "define", []string{}, "replace global identifiers with constant expressions")
+		f.StringArrayVar(&alias, "alias", []string{}, "alias packages")
+	}
+
+	i18nUpdateCommand.Flags().StringArrayVar(&languages, "add", []string{}, "start a translation for this language, can be repeated")
+
+	i18nCommand.AddCommand(i18nExtractCommand)
+	i18nCommand.AddCommand(i18nUpdateCommand)
+}
+
+func projectRoot(dir string) string {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		lib.Err(err)
+	}
+	return root
+}
+
+// the --domain flag, the domain of ags.json or the name of the project directory
+func gettextDomain(root string) string {
+	if domain != "" {
+		return domain
+	}
+	if config := lib.LoadProjectConfig(root); config.Domain != "" {
+		return config.Domain
+	}
+	return filepath.Base(projectRoot(root))
+}
+
+// source files of the module graph of every program of the project
+func i18nSources(root string) []string {
+	var infiles []string
+	if len(lib.LoadProjectConfig(root).Programs) > 0 {
+		for _, p := range workspacePrograms(root, nil, nil) {
+			infiles = append(infiles, p.Entry)
+		}
+	} else {
+		infiles = append(infiles, getAppEntry(root))
+	}
+
+	var files []string
+	for _, infile := range infiles {
+		result := lib.Bundle(lib.BundleOpts{
+			Infile:           infile,
+			WorkingDirectory: root,
+			Defines:          defines,
+			Alias:            alias,
+			GtkVersion:       inferGtkVersion(infile, root),
+			Sourcemap:        "none",
+			LegalComments:    "none",
+			Metafile:         true,
+			SkipTypelibCheck: true,
+		})
+
+		for input := range lib.ParseMetafile(result.Metafile).Inputs {
+			// blueprints mark translatable strings the same way
+			file, isBlp := strings.CutPrefix(input, "blueprint:")
+			if strings.Contains(file, ":") && !isBlp {
+				continue
+			}
+
+			switch filepath.Ext(file) {
+			case ".ts", ".tsx", ".js", ".jsx", ".mjs", ".mts", ".blp":
+			default:
+				continue
+			}
+
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(root, file)
+			}
+
+			// modules of packages are translated by their own domain
+			rel, err := filepath.Rel(root, file)
+			if err != nil || strings.HasPrefix(rel, "..") || strings.Contains(rel, "node_modules") {
+				continue
+			}
+
+			if !slices.Contains(files, file) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	slices.Sort(files)
+	return files
+}
+
+// writes the template of the messages to po/<domain>.pot
+func extractMessages(root, domain string) *lib.Po {
+	pot := lib.ExtractMessages(root, i18nSources(root), domain)
+
+	path := filepath.Join(root, poDir, domain+".pot")
+	if _, err := lib.WriteIfChanged(path, pot.String()); err != nil {
+		lib.Err(err)
+	}
+
+	fmt.Printf("%s %d messages\n", lib.Cyan(filepath.Join(poDir, domain+".pot")), len(pot.Entries)-1)
+	return pot
+}
+
+// merges the template into every catalog and starts the ones of --add
+func updateCatalogs(root string, pot *lib.Po) {
+	for _, lang := range languages {
+		path := filepath.Join(root, poDir, lang+".po")
+		if !lib.FileExists(path) {
+			lib.WriteFile(path, lib.NewPo(pot, lang).String())
+		}
+	}
+
+	catalogs := loadCatalogs(root)
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+
+	for _, lang := range langs {
+		po := catalogs[lang].Merge(pot)
+		lib.WriteFile(filepath.Join(root, poDir, lang+".po"), po.String())
+
+		translated, fuzzy, untranslated := po.Statistics()
+		fmt.Printf("%s %d translated, %d fuzzy, %d untranslated\n",
+			lib.Cyan(filepath.Join(poDir, lang+".po")), translated, fuzzy, untranslated)
+	}
+}
+
+// translations of the project keyed by their language
+func loadCatalogs(root string) map[string]*lib.Po {
+	files, _ := filepath.Glob(filepath.Join(root, poDir, "*.po"))
+	catalogs := map[string]*lib.Po{}
+	for _, file := range files {
+		po, err := lib.ParsePo(string(lib.ReadFile(file)))
+		if err != nil {
+			lib.Err(fmt.Sprintf("%s: %v", file, err))
+		}
+		catalogs[strings.TrimSuffix(filepath.Base(file), ".po")] = po
+	}
+	return catalogs
+}
+
+// compiles the translations into dir/<lang>/LC_MESSAGES/<domain>.mo,
+// returns the domain or nothing when the project is not translated
+func compileLocales(root, dir string) string {
+	catalogs := loadCatalogs(root)
+	if len(catalogs) == 0 {
+		return ""
+	}
+
+	domain := gettextDomain(root)
+	for lang, po := range catalogs {
+		path := filepath.Join(dir, lang, "LC_MESSAGES", domain+".mo")
+		lib.Mkdir(filepath.Dir(path))
+		if err := os.WriteFile(path, po.Mo(), 0644); err != nil {
+			lib.Err(err)
+		}
+	}
+	return domain
+}
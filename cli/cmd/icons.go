@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var iconsCommand = &cobra.Command{
+	Use:   "icons",
+	Short: "Manage the icons of a project",
+}
+
+var iconsBuildCommand = &cobra.Command{
+	Use:   "build [src] [out]",
+	Short: "Assemble loose icons into an icon theme",
+	Long: `Assemble the svg and png icons of src into a hicolor icon theme in out,
+which can be added with App.add_icons(out).
+
+Svg icons are scalable, png icons are placed by their size and "@2x" or
+"@3x" suffixes mark icons for scaled displays. The context of an icon is
+read from a parent directory such as "actions" or "status" and defaults to
+"apps". Icons in a "symbolic" directory are renamed to end with "-symbolic".
+
+ags run and ags bundle assemble and ship the icons/ directory of a project,
+or the one set by "icons" in ags.json.`,
+	Example: `  ags icons build src/icons out/icons`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := lib.BuildIconTheme(args[0], args[1])
+		if err != nil {
+			lib.Err(err)
+		}
+		if len(files) == 0 {
+			lib.Err("no svg or png icons in " + args[0])
+		}
+		fmt.Printf("%s %d icons\n", lib.Cyan(filepath.Join(args[1], "hicolor")), len(files)-1)
+	},
+}
+
+func init() {
+	iconsCommand.AddCommand(iconsBuildCommand)
+}
+
+// the directory of the icons of a project, nothing when it has none
+func iconsSource(root string) string {
+	dir := lib.LoadProjectConfig(root).Icons
+	if dir == "" {
+		dir = "icons"
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	if !lib.FileExists(dir) {
+		return ""
+	}
+	return dir
+}
+
+// assembles the icons of root into a theme in dir,
+// returns the files of the theme which are relative to dir
+func buildIcons(root, dir string) []string {
+	src := iconsSource(root)
+	if src == "" {
+		return nil
+	}
+
+	files, err := lib.BuildIconTheme(src, dir)
+	if err != nil {
+		lib.Err(err)
+	}
+
+	for i, file := range files {
+		files[i], _ = filepath.Rel(dir, file)
+	}
+	return files
+}
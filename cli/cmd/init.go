@@ -3,9 +3,12 @@ package cmd
 
 import (
 	"ags/lib"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -14,13 +17,39 @@ import (
 var (
 	force         bool
 	initDirectory string
+	projectName   string
+	initTemplate  string
+	eslint        bool
+	prettier      bool
+	gitInit       bool
+	assumeYes     bool
+	templateVars  []string
+	dryRun        bool
+	runPostInit   bool
 )
 
+type builtinTemplate struct {
+	Name        string
+	Description string
+}
+
+var builtinTemplates = []builtinTemplate{
+	{"bar", "status bar with a clock"},
+	{"launcher", "application launcher"},
+	{"osd", "volume on-screen display"},
+	{"notifications", "notification daemon with popups"},
+	{"blank", "empty app"},
+}
+
 var initCommand = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize a project directory",
 	Long: `Initialize a project directory by setting up files needed by TypeScript,
-generating types and setting up a basic bar example`,
+generating types and setting up an example from a template.
+
+When stdin is a terminal, options which were not passed as flags are prompted for.`,
+	Example: `  ags init -d ~/.config/ags --template launcher --eslint --yes
+  ags init --template ./my-template.tar.gz --var author=me`,
 	Args: cobra.NoArgs,
 	Run:  initConfig,
 }
@@ -29,8 +58,28 @@ func init() {
 	f := initCommand.Flags()
 
 	f.UintVarP(&gtkVersion, "gtk", "g", 4, "gtk version")
-	f.BoolVarP(&force, "force", "f", false, "override existing files")
+	f.BoolVarP(&force, "force", "f", false, "initialize an existing directory, merging configs and keeping other files")
+	f.BoolVar(&dryRun, "dry-run", false, "only print which files would be created, updated or skipped")
 	f.StringVarP(&initDirectory, "directory", "d", defaultConfigDir(), "target directory")
+	f.StringVarP(&projectName, "name", "n", "", "project name, defaults to the name of the directory")
+	f.StringVarP(&initTemplate, "template", "t", "bar",
+		"template to start from: "+templateNames()+"\nor a directory, archive or git url")
+	f.StringArrayVar(&templateVars, "var", []string{}, "set a variable of a template")
+	f.BoolVar(&eslint, "eslint", false, "generate an ESLint config")
+	f.BoolVar(&prettier, "prettier", true, "generate a Prettier config")
+	f.BoolVar(&gitInit, "git", false, "initialize a git repository")
+	f.BoolVarP(&assumeYes, "yes", "y", false, "do not prompt, use flags and defaults")
+	f.BoolVar(&runPostInit, "run-post-init", false, "run the postInit commands of a template without asking")
+	f.StringVar(&typesGenerator, "generator", "ts-for-gir", "type generator to use: ts-for-gir or native")
+	f.StringVar(&tsForGir, "ts-for-gir", "", "path to a local ts-for-gir executable instead of npx")
+}
+
+func templateNames() string {
+	names := make([]string, len(builtinTemplates))
+	for i, t := range builtinTemplates {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
 }
 
 func getDataFile(name string) string {
@@ -41,13 +90,128 @@ func getDataFile(name string) string {
 	return string(content)
 }
 
-// TODO: interactive init, to ask for
-// project dir
-// project name
-// gtk version
-// eslint
-// prettier or eslint stylistic
+// ask for options which were not explicitly set with flags
+func promptInitOptions(cmd *cobra.Command) {
+	f := cmd.Flags()
+
+	if !f.Changed("directory") {
+		initDirectory = lib.Prompt("Project directory", initDirectory)
+	}
+
+	if !f.Changed("name") {
+		dir, _ := filepath.Abs(initDirectory)
+		projectName = lib.Prompt("Project name", filepath.Base(dir))
+	}
+
+	if !f.Changed("template") {
+		options := make([]string, len(builtinTemplates))
+		for i, t := range builtinTemplates {
+			options[i] = fmt.Sprintf("%s %s", t.Name, lib.Blue("- "+t.Description))
+		}
+		options = append(options, "custom "+lib.Blue("- directory, archive or git url"))
+
+		if i := lib.Select("Template", options, 0); i < len(builtinTemplates) {
+			initTemplate = builtinTemplates[i].Name
+		} else {
+			initTemplate = lib.Prompt("Template location", "")
+		}
+	}
+
+	if !f.Changed("gtk") {
+		if lib.Select("Gtk version", []string{"Gtk4", "Gtk3"}, 0) == 1 {
+			gtkVersion = 3
+		} else {
+			gtkVersion = 4
+		}
+	}
+
+	if !f.Changed("eslint") {
+		eslint = lib.Confirm("Use ESLint?", eslint)
+	}
+
+	if !f.Changed("prettier") {
+		prettier = lib.Confirm("Use Prettier?", prettier)
+	}
+
+	if !f.Changed("git") {
+		gitInit = lib.Confirm("Initialize a git repository?", gitInit)
+	}
+}
+
+// copy the files of a builtin template into the project
+func copyTemplate(s *scaffold, name, gtk string) {
+	root := "data/templates/" + name
+
+	err := fs.WalkDir(env.Data, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, ok := templatePath(strings.TrimPrefix(path, root+"/"), gtk)
+		if !ok {
+			return nil
+		}
+
+		s.WriteString(rel, getDataFile(strings.TrimPrefix(path, "data/")))
+		return nil
+	})
+	if err != nil {
+		lib.Err(err)
+	}
+}
+
+func packageJson(name string) string {
+	var pkg map[string]any
+	if err := json.Unmarshal([]byte(getDataFile("package.json")), &pkg); err != nil {
+		lib.Err(err)
+	}
+
+	pkg["name"] = name
+	devDependencies := map[string]any{}
+
+	if eslint {
+		devDependencies["@eslint/js"] = "^9.39.1"
+		devDependencies["eslint"] = "^9.39.1"
+		devDependencies["typescript"] = "^5.9.3"
+		devDependencies["typescript-eslint"] = "^8.48.0"
+		pkg["scripts"] = map[string]any{"lint": "eslint . --fix"}
+	}
+
+	if prettier {
+		devDependencies["prettier"] = "^3.6.2"
+	} else {
+		delete(pkg, "prettier")
+	}
+
+	if len(devDependencies) > 0 {
+		pkg["devDependencies"] = devDependencies
+	}
+
+	content, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		lib.Err(err)
+	}
+
+	return string(content) + "\n"
+}
+
 func initConfig(cmd *cobra.Command, args []string) {
+	checkGenerator(typesGenerator)
+
+	interactive := lib.IsInteractive() && !assumeYes
+	if interactive {
+		promptInitOptions(cmd)
+	}
+
+	var external *projectTemplate
+	if !slices.ContainsFunc(builtinTemplates, func(t builtinTemplate) bool {
+		return t.Name == initTemplate
+	}) {
+		external = loadTemplate(initTemplate)
+		defer external.cleanup()
+		external.resolveVariables(lib.SliceToKV(templateVars), interactive)
+	}
+
 	gtk := "gtk4"
 	if gtkVersion == 3 {
 		gtk = "gtk3"
@@ -57,27 +221,63 @@ func initConfig(cmd *cobra.Command, args []string) {
 	if err != nil {
 		lib.Err(err)
 	}
-	if info, err := os.Stat(initDir); err == nil && info.IsDir() && !force {
-		lib.Err("could not initialize: " + lib.Cyan(initDir) + " already exists")
+	exists := false
+	if info, err := os.Stat(initDir); err == nil && info.IsDir() {
+		exists = true
+		if !force && !dryRun {
+			lib.Err("could not initialize: " + lib.Cyan(initDir) + " already exists\n" +
+				lib.Cyan("tip: ") + "use --force to merge into it or --dry-run to preview the changes")
+		}
+	}
+
+	if projectName == "" {
+		projectName = filepath.Base(initDir)
+	}
+
+	s := newScaffold(initDir, exists, dryRun)
+
+	s.WriteString(".gitignore", getDataFile("gitignore"))
+	s.WriteString("tsconfig.json", substitute(getDataFile("tsconfig.json"), gtk))
+	s.WriteString("package.json", packageJson(projectName))
+	s.WriteString("env.d.ts", getDataFile("env.d.ts"))
+	if external != nil {
+		external.copy(s, projectName, gtk)
+	} else {
+		copyTemplate(s, initTemplate, gtk)
+	}
+	s.Link(env.AgsJsPackage, "node_modules/ags")
+	s.Link(env.AgsJsPackage+"/node_modules/gnim", "node_modules/gnim")
+
+	if eslint {
+		s.WriteString("eslint.config.js", getDataFile("eslint.config.js"))
 	}
 
-	tsconf := getDataFile("tsconfig.json")
-	tsconf = strings.ReplaceAll(tsconf, "@AGS_JS_PACKAGE@", env.AgsJsPackage)
-	tsconf = strings.ReplaceAll(tsconf, "@GTK_VERSION@", gtk)
+	if dryRun {
+		s.PrintSummary()
+		return
+	}
 
-	lib.Mkdir(initDir + "/widget")
-	lib.Mkdir(initDir + "/node_modules")
+	if gitInit && !lib.FileExists(initDir+"/.git") {
+		git := lib.Exec("git", "init", "--quiet")
+		git.Dir = initDir
+		git.Stderr = os.Stderr
+		if err := git.Run(); err != nil {
+			lib.Err(err)
+		}
+	}
 
-	lib.WriteFile(initDir+"/.gitignore", getDataFile("gitignore"))
-	lib.WriteFile(initDir+"/tsconfig.json", tsconf)
-	lib.WriteFile(initDir+"/package.json", getDataFile("package.json"))
-	lib.WriteFile(initDir+"/env.d.ts", getDataFile("env.d.ts"))
-	lib.WriteFile(initDir+"/style.scss", getDataFile("style.scss"))
-	lib.WriteFile(initDir+"/widget/Bar.tsx", getDataFile(gtk+"/Bar.tsx"))
-	lib.WriteFile(initDir+"/app.ts", getDataFile(gtk+"/app.ts"))
-	lib.Ln(env.AgsJsPackage, initDir+"/node_modules/ags")
-	lib.Ln(env.AgsJsPackage+"/node_modules/gnim", initDir+"/node_modules/gnim")
+	if external != nil {
+		external.postInit(initDir, projectName, gtk, interactive)
+	}
 
-	genTypes(initDir, "*", false)
+	genTypes(initDir, []string{"*"}, typesGenerator, false)
+	if exists {
+		s.PrintSummary()
+	}
 	fmt.Println(lib.Green("project ready") + " at " + lib.Cyan(initDir))
+
+	if eslint || prettier {
+		fmt.Println(lib.Cyan("tip: ") + "run " + lib.Yellow("npm install") +
+			" to install the linting and formatting tools")
+	}
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"ags/lib"
 	"embed"
 	"os"
 	"path/filepath"
@@ -15,6 +16,9 @@ var (
 	alias      []string
 	workingDir string
 	env        Env
+
+	skipTypelibCheck bool
+	strictCss        bool
 )
 
 var rootCmd = &cobra.Command{
@@ -33,6 +37,7 @@ type Env struct {
 	Gjs            string
 	Cat            string
 	Base64         string
+	Mkdir          string
 }
 
 func Initialize(_env Env) {
@@ -52,21 +57,22 @@ func init() {
 	rootCmd.AddCommand(quitCommand)
 	rootCmd.AddCommand(typesCommand)
 	rootCmd.AddCommand(bundleCommand)
+	rootCmd.AddCommand(analyzeCommand)
+	rootCmd.AddCommand(depsCommand)
 	rootCmd.AddCommand(initCommand)
+	rootCmd.AddCommand(i18nCommand)
+	rootCmd.AddCommand(iconsCommand)
+	rootCmd.AddCommand(themeCommand)
+	rootCmd.AddCommand(syncCommand)
 }
 
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		lib.Exit(1)
 	}
 
-	help, _ := rootCmd.Flags().GetBool("help")
-	ver, _ := rootCmd.Flags().GetBool("version")
-
-	if help || ver {
-		os.Exit(0)
-	}
+	lib.Exit(0)
 }
 
 func defaultConfigDir() string {
@@ -7,6 +7,9 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/spf13/cobra"
 )
@@ -18,11 +21,22 @@ var (
 )
 
 var runCommand = &cobra.Command{
-	Use:     "run [file] [gjsArgs...]",
-	Short:   "Run an app",
-	Example: "  run app.ts --define DEBUG=true",
-	Args:    cobra.ArbitraryArgs,
+	Use:   "run [file] [gjsArgs...]",
+	Short: "Run an app",
+	Example: `  run app.ts --define DEBUG=true
+  run --entry bar --entry launcher/main.ts
+  run --program bar -d /path/to/workspace`,
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		defines = withEnvDefines(defines)
+
+		workspace := len(lib.LoadProjectConfig(targetDir).Programs) > 0
+		if len(entries) > 0 || len(programs) > 0 || len(args) == 0 && workspace {
+			gjsArgs = args
+			runWorkspace(targetDir, workspacePrograms(targetDir, entries, programs))
+			return
+		}
+
 		if len(args) > 0 {
 			path, err := filepath.Abs(args[0])
 			if err != nil {
@@ -58,6 +72,12 @@ when no positional argument is given
 	f.StringArrayVar(&alias, "alias", []string{}, "alias packages")
 	f.UintVarP(&gtkVersion, "gtk", "g", 0, "gtk version")
 	f.StringVar(&logFile, "log-file", "", "file to redirect the stdout of gjs to")
+	f.BoolVar(&skipTypelibCheck, "skip-typelib-check", false, "do not check if imported typelibs are installed")
+	f.BoolVar(&strictCss, "strict-css", false, "fail on css which gtk does not support instead of warning")
+	f.StringArrayVar(&fontDirs, "fonts", []string{}, "directory of fonts to register for the app, can be repeated")
+	envFlags(runCommand)
+	f.StringArrayVarP(&entries, "entry", "e", []string{}, "entry file or directory of a program, can be repeated")
+	f.StringArrayVarP(&programs, "program", "p", []string{}, "only run this program of the workspace, can be repeated")
 	f.MarkHidden("package")
 	f.MarkHidden("alias")
 }
@@ -114,21 +134,51 @@ func logging() (io.Writer, io.Writer, *os.File) {
 }
 
 func run(infile string, rootdir string) {
-	if gtkVersion == 0 {
-		gtkVersion = inferGtkVersion(infile)
-	}
+	gtkVersion = inferGtkVersion(infile, rootdir)
 
 	outfile := getOutfile()
 
-	lib.Bundle(lib.BundleOpts{
+	root := rootdir
+	if root == "" {
+		root = filepath.Dir(infile)
+	}
+
+	schemadir := strings.TrimSuffix(outfile, ".js") + "-schemas"
+	if compileSchemas(root, schemadir) {
+		os.Setenv("GSETTINGS_SCHEMA_DIR", schemaDirs(schemadir))
+	}
+
+	localedir := strings.TrimSuffix(outfile, ".js") + "-locale"
+	lib.Rm(localedir)
+	textDomain := compileLocales(root, localedir)
+	if textDomain != "" {
+		os.Setenv("AGS_LOCALE_DIR", localedir)
+	}
+
+	iconsdir := strings.TrimSuffix(outfile, ".js") + "-icons"
+	if len(buildIcons(root, iconsdir)) > 0 {
+		os.Setenv("AGS_ICONS_DIR", iconsdir)
+	}
+
+	result := lib.Bundle(lib.BundleOpts{
 		Infile:           infile,
 		Outfile:          outfile,
 		Defines:          defines,
 		Alias:            alias,
 		GtkVersion:       gtkVersion,
 		WorkingDirectory: rootdir,
+		SkipTypelibCheck: skipTypelibCheck,
+		StrictCss:        strictCss,
+		TextDomain:       textDomain,
+		Metafile:         true,
 	})
 
+	if fonts := bundleFonts(metafileDir(rootdir), result.Metafile); len(fonts) > 0 {
+		fontdir := strings.TrimSuffix(outfile, ".js") + "-fonts"
+		installFonts(fonts, fontdir, fontdir, fontconfigInclude())
+		os.Setenv("FONTCONFIG_FILE", filepath.Join(fontdir, "fonts.conf"))
+	}
+
 	if gtkVersion == 4 {
 		os.Setenv("LD_PRELOAD", env.Gtk4LayerShell)
 	}
@@ -139,11 +189,20 @@ func run(infile string, rootdir string) {
 	gjs.Stdin = os.Stdin
 	gjs.Dir = filepath.Dir(infile)
 
-	gjs.Stdout = stdout
-	gjs.Stderr = stderr
+	// rewrite locations in stack traces to the original sources
+	maps := lib.InlineSourceMaps(outfile)
+	out := lib.NewSourceMapWriter(stdout, maps, root)
+	errout := lib.NewSourceMapWriter(stderr, maps, root)
+
+	gjs.Stdout = out
+	gjs.Stderr = errout
 
 	// TODO: watch and restart
-	if err := gjs.Run(); err != nil {
+	err := gjs.Run()
+	out.Flush()
+	errout.Flush()
+
+	if err != nil {
 		lib.Err(err)
 	}
 
@@ -151,3 +210,109 @@ func run(infile string, rootdir string) {
 		file.Close()
 	}
 }
+
+// runs every program of a workspace in its own gjs process,
+// modules they share are bundled once into chunks
+func runWorkspace(root string, progs []program) {
+	if len(progs) == 0 {
+		lib.Err("no programs to run")
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		lib.Err(err)
+	}
+
+	outdir := strings.TrimSuffix(getOutfile(), ".js")
+	lib.Rm(outdir)
+
+	schemadir := filepath.Join(outdir, "schemas")
+	schemas := compileSchemas(root, schemadir)
+
+	localedir := filepath.Join(outdir, "locale")
+	textDomain := compileLocales(root, localedir)
+
+	iconsdir := filepath.Join(outdir, "icons")
+	icons := len(buildIcons(root, iconsdir)) > 0
+
+	var metafiles []string
+	for gtk, group := range programsByGtk(progs) {
+		result := lib.Bundle(lib.BundleOpts{
+			Entries:          bundleEntries(group),
+			Outdir:           outdir,
+			Defines:          defines,
+			Alias:            alias,
+			GtkVersion:       gtk,
+			WorkingDirectory: root,
+			SkipTypelibCheck: skipTypelibCheck,
+			StrictCss:        strictCss,
+			TextDomain:       textDomain,
+			Metafile:         true,
+		})
+		metafiles = append(metafiles, result.Metafile)
+	}
+
+	fontdir := filepath.Join(outdir, "fonts")
+	fonts := bundleFonts(root, metafiles...)
+	if len(fonts) > 0 {
+		installFonts(fonts, fontdir, fontdir, fontconfigInclude())
+	}
+
+	bundles, _ := filepath.Glob(filepath.Join(outdir, "*.js"))
+	chunks, _ := filepath.Glob(filepath.Join(outdir, "chunks", "*.js"))
+	maps := lib.InlineSourceMaps(append(bundles, chunks...)...)
+
+	stdout, stderr, file := logging()
+
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	for _, p := range progs {
+		prefix := []byte(lib.Magenta(p.Name) + " ")
+		out := lib.NewSourceMapWriter(&prefixWriter{w: stdout, prefix: prefix}, maps, root)
+		errout := lib.NewSourceMapWriter(&prefixWriter{w: stderr, prefix: prefix}, maps, root)
+
+		args := append([]string{"-m", filepath.Join(outdir, p.Name+".js")}, gjsArgs...)
+		gjs := lib.Exec("gjs", args...)
+		gjs.Dir = filepath.Dir(p.Entry)
+		gjs.Stdout = out
+		gjs.Stderr = errout
+		gjs.Env = append(os.Environ(), "AGS_INSTANCE_NAME="+p.Instance)
+		if schemas {
+			gjs.Env = append(gjs.Env, "GSETTINGS_SCHEMA_DIR="+schemaDirs(schemadir))
+		}
+		if textDomain != "" {
+			gjs.Env = append(gjs.Env, "AGS_LOCALE_DIR="+localedir)
+		}
+		if icons {
+			gjs.Env = append(gjs.Env, "AGS_ICONS_DIR="+iconsdir)
+		}
+		if len(fonts) > 0 {
+			gjs.Env = append(gjs.Env, "FONTCONFIG_FILE="+filepath.Join(fontdir, "fonts.conf"))
+		}
+		if p.Gtk == 4 {
+			gjs.Env = append(gjs.Env, "LD_PRELOAD="+env.Gtk4LayerShell)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := gjs.Run()
+			out.Flush()
+			errout.Flush()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s %v\n", lib.Red("error:"), p.Name, err)
+				failed.Store(true)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if file != nil {
+		file.Close()
+	}
+
+	if failed.Load() {
+		lib.Exit(1)
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// files which are merged with their existing counterpart instead of being skipped
+var mergeableFiles = []string{"package.json", "tsconfig.json"}
+
+type scaffoldAction struct {
+	Action string
+	Path   string
+}
+
+// scaffold writes the files of a project, when merging into an existing directory
+// files of the user are left untouched and only json configs are merged
+type scaffold struct {
+	Dir    string
+	Merge  bool
+	DryRun bool
+
+	actions []scaffoldAction
+	created map[string]bool
+}
+
+func newScaffold(dir string, merge, dryRun bool) *scaffold {
+	return &scaffold{
+		Dir:     dir,
+		Merge:   merge,
+		DryRun:  dryRun,
+		created: map[string]bool{},
+	}
+}
+
+func (s *scaffold) record(action, rel string) {
+	i := slices.IndexFunc(s.actions, func(a scaffoldAction) bool { return a.Path == rel })
+	if i == -1 {
+		s.actions = append(s.actions, scaffoldAction{action, rel})
+	} else if s.actions[i].Action != "created" {
+		s.actions[i].Action = action
+	}
+}
+
+func (s *scaffold) writeFile(rel string, content []byte, perm os.FileMode) {
+	if s.DryRun {
+		return
+	}
+
+	path := filepath.Join(s.Dir, rel)
+	lib.Mkdir(filepath.Dir(path))
+	if err := os.WriteFile(path, content, perm); err != nil {
+		lib.Err(err)
+	}
+}
+
+// Write creates a file relative to the project directory,
+// existing files are skipped or merged in merge mode
+func (s *scaffold) Write(rel string, content []byte, perm os.FileMode) {
+	rel = filepath.ToSlash(rel)
+	exists := lib.FileExists(filepath.Join(s.Dir, rel))
+
+	if !exists || s.created[rel] {
+		s.created[rel] = true
+		s.record("created", rel)
+		s.writeFile(rel, content, perm)
+		return
+	}
+
+	if !s.Merge {
+		s.record("updated", rel)
+		s.writeFile(rel, content, perm)
+		return
+	}
+
+	if !slices.Contains(mergeableFiles, rel) {
+		s.record("skipped", rel)
+		return
+	}
+
+	merged, changed, err := lib.MergeJson(lib.ReadFile(filepath.Join(s.Dir, rel)), content)
+	if err != nil {
+		lib.Err(rel + ": " + err.Error())
+	}
+
+	if !changed {
+		s.record("skipped", rel)
+		return
+	}
+
+	s.record("updated", rel)
+	s.writeFile(rel, merged, perm)
+}
+
+// WriteString is Write for text files with the default permissions
+func (s *scaffold) WriteString(rel, content string) {
+	s.Write(rel, []byte(content), 0644)
+}
+
+// Link creates a symlink relative to the project directory,
+// existing symlinks are re-pointed to target
+func (s *scaffold) Link(target, rel string) {
+	path := filepath.Join(s.Dir, rel)
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		s.record("created", rel)
+		if !s.DryRun {
+			lib.Mkdir(filepath.Dir(path))
+			lib.Ln(target, path)
+		}
+		return
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		// not ours to replace, e.g. installed by npm
+		s.record("skipped", rel)
+		return
+	}
+
+	if current, _ := os.Readlink(path); current == target {
+		s.record("skipped", rel)
+		return
+	}
+
+	s.record("updated", rel)
+	if !s.DryRun {
+		lib.Rm(path)
+		lib.Ln(target, path)
+	}
+}
+
+func (s *scaffold) PrintSummary() {
+	colors := map[string]func(string) string{
+		"created": lib.Green,
+		"updated": lib.Yellow,
+		"skipped": lib.Blue,
+	}
+
+	prefix := ""
+	if s.DryRun {
+		prefix = "would be "
+	}
+
+	for _, a := range s.actions {
+		fmt.Printf("%s %s\n", colors[a.Action](prefix+a.Action), a.Path)
+	}
+}
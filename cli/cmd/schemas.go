@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"ags/lib"
+	"os"
+	"path/filepath"
+)
+
+// compiles the GSettings schemas found in root into dir/gschemas.compiled
+// and declares their keys in root/gschemas.d.ts, reports if there were any
+func compileSchemas(root, dir string) bool {
+	files := lib.FindGSchemas(root)
+	if len(files) == 0 {
+		return false
+	}
+
+	schemas, err := lib.ParseGSchemas(files)
+	if err != nil {
+		lib.Err(err)
+	}
+
+	schemas.WriteDts(filepath.Join(root, "gschemas.d.ts"))
+
+	if err := lib.CompileGSchemas(schemas, dir); err != nil {
+		lib.Err(err)
+	}
+
+	return true
+}
+
+// value of GSETTINGS_SCHEMA_DIR which searches dir before the directories already set
+func schemaDirs(dir string) string {
+	if existing := os.Getenv("GSETTINGS_SCHEMA_DIR"); existing != "" {
+		return dir + ":" + existing
+	}
+	return dir
+}
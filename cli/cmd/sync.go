@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// declarations of the --define flags in the root of a project
+const definesDts = "ags-env.d.ts"
+
+var syncCommand = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync defines and aliases into the editor configuration",
+	Long: `Declare the identifiers replaced by --define in ags-env.d.ts with types
+inferred from their values, declare the variables of --env-file in
+ImportMetaEnv and mirror --alias into the paths of tsconfig.json,
+so that the TypeScript language server agrees with the bundler.
+Mirrored paths are marked with /* ags sync */ and removed again
+once their alias is no longer passed.
+
+Pass the same --define, --alias and --env-file flags as to ags run and ags bundle.`,
+	Example: `  ags sync --define DEBUG=true --define 'DATADIR="/usr/share/my-shell"'
+  ags sync --alias utils=./src/utils -d /path/to/project`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		syncEditorConfig(projectRoot(targetDir))
+	},
+}
+
+func init() {
+	f := syncCommand.Flags()
+	f.StringVarP(&targetDir, "directory", "d", defaultConfigDir(), "directory of the project")
+	f.StringArrayVar(&defines, "define", []string{}, "replace global identifiers with constant expressions")
+	f.StringArrayVar(&alias, "alias", []string{}, "alias packages")
+	envFlags(syncCommand)
+}
+
+// mirrors the aliases into the paths of tsconfig.json, which is only
+// touched when there are aliases or previously mirrored ones to remove
+func syncTsconfigPaths(root string) {
+	path := filepath.Join(root, "tsconfig.json")
+	kv := lib.SliceToKV(alias)
+
+	var tsconfig []byte
+	switch {
+	case lib.FileExists(path):
+		tsconfig = lib.ReadFile(path)
+	case len(kv) > 0:
+		tsconfig = []byte(lib.GetTsconfig(root, 0))
+	default:
+		return
+	}
+
+	tsconfig, changed, err := lib.SyncTsconfigPaths(tsconfig, kv)
+	if err != nil {
+		lib.Err(path + ": " + err.Error())
+	}
+	if changed {
+		lib.WriteFile(path, string(tsconfig))
+		fmt.Println(lib.Cyan("tsconfig.json") + " updated")
+	}
+}
+
+// mirrors the defines into ags-env.d.ts and the aliases into tsconfig.json
+func syncEditorConfig(root string) {
+	syncTsconfigPaths(root)
+
+	dts := filepath.Join(root, definesDts)
+	kv := lib.SliceToKV(withEnvDefines(defines))
+	delete(kv, "SRC")
+	if len(kv) == 0 && !lib.FileExists(dts) {
+		return
+	}
+	if changed, err := lib.WriteIfChanged(dts, lib.DefinesDts(kv)); err != nil {
+		lib.Err(err)
+	} else if changed {
+		fmt.Println(lib.Cyan(definesDts) + " updated")
+	}
+}
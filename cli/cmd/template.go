@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"ags/lib"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/titanous/json5"
+)
+
+// file in the root of a third party template describing it
+const templateManifest = "ags-template.json"
+
+type templatePrompt struct {
+	Name    string   `json:"name"`
+	Message string   `json:"message"`
+	Default any      `json:"default"`
+	Options []string `json:"options"`
+}
+
+type projectTemplate struct {
+	Dir string `json:"-"`
+
+	// default values of variables available in rendered files
+	Variables map[string]any `json:"variables"`
+
+	// variables to ask for when running interactively
+	Prompts []templatePrompt `json:"prompts"`
+
+	// glob patterns of files which are rendered with text/template
+	Render []string `json:"render"`
+
+	// glob patterns of files which are not copied
+	Ignore []string `json:"ignore"`
+
+	// shell commands executed in the project directory after initializing
+	PostInit []string `json:"postInit"`
+
+	cleanup func()
+}
+
+// patterns without a slash match the base name in any directory
+func matchAny(patterns []string, rel string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		if !strings.Contains(pattern, "/") {
+			ok, _ := path.Match(pattern, path.Base(rel))
+			return ok
+		}
+		ok, _ := path.Match(pattern, rel)
+		return ok
+	})
+}
+
+// files in the gtk3 and gtk4 subdirectories of a template only apply to the given version
+func templatePath(rel, gtk string) (string, bool) {
+	if dir, file, ok := strings.Cut(rel, "/"); ok && (dir == "gtk3" || dir == "gtk4") {
+		return file, dir == gtk
+	}
+	return rel, true
+}
+
+func substitute(content, gtk string) string {
+	content = strings.ReplaceAll(content, "@AGS_JS_PACKAGE@", env.AgsJsPackage)
+	content = strings.ReplaceAll(content, "@GTK_VERSION@", gtk)
+	return content
+}
+
+// archives usually wrap everything in a single directory
+func unwrapDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name())
+	}
+	return dir
+}
+
+// resolve a --template argument to a local directory
+// which is either a directory, an archive or a git repository to clone
+func fetchTemplate(src string) (string, func()) {
+	if info, err := os.Stat(src); err == nil && info.IsDir() {
+		dir, err := filepath.Abs(src)
+		if err != nil {
+			lib.Err(err)
+		}
+		return dir, func() {}
+	}
+
+	tmp, cleanup := lib.TempDir("ags-template-")
+
+	if lib.IsArchive(src) && lib.FileExists(src) {
+		if err := lib.Extract(src, tmp); err != nil {
+			lib.Err(err)
+		}
+		return unwrapDir(tmp), cleanup
+	}
+
+	if strings.Contains(src, "://") || strings.HasPrefix(src, "git@") {
+		git := lib.Exec("git", "clone", "--quiet", "--depth", "1", src, tmp)
+		git.Stderr = os.Stderr
+		if err := git.Run(); err != nil {
+			lib.Err("failed to clone " + lib.Cyan(src))
+		}
+		return tmp, cleanup
+	}
+
+	lib.Err("unknown template " + lib.Cyan(src) + "\n" +
+		lib.Cyan("tip: ") + "valid templates are: " + templateNames() +
+		", a directory, a .tar.gz/.zip archive or a git url")
+	return "", nil
+}
+
+func loadTemplate(src string) *projectTemplate {
+	dir, cleanup := fetchTemplate(src)
+	tmpl := &projectTemplate{
+		Dir:       dir,
+		Variables: map[string]any{},
+		cleanup:   cleanup,
+	}
+
+	if manifest := filepath.Join(dir, templateManifest); lib.FileExists(manifest) {
+		if err := json5.Unmarshal(lib.ReadFile(manifest), tmpl); err != nil {
+			lib.Err(templateManifest + ": " + err.Error())
+		}
+	}
+
+	return tmpl
+}
+
+// fill in variables from --var flags and prompts
+func (t *projectTemplate) resolveVariables(vars map[string]string, interactive bool) {
+	for key, value := range vars {
+		t.Variables[key] = value
+	}
+
+	for _, p := range t.Prompts {
+		if _, ok := vars[p.Name]; ok {
+			continue
+		}
+
+		message := p.Message
+		if message == "" {
+			message = p.Name
+		}
+
+		switch def := p.Default.(type) {
+		case bool:
+			t.Variables[p.Name] = def
+			if interactive {
+				t.Variables[p.Name] = lib.Confirm(message, def)
+			}
+		default:
+			fallback := ""
+			if def != nil {
+				fallback = fmt.Sprint(def)
+			}
+
+			t.Variables[p.Name] = fallback
+			if interactive && len(p.Options) > 0 {
+				i := max(slices.Index(p.Options, fallback), 0)
+				t.Variables[p.Name] = p.Options[lib.Select(message, p.Options, i)]
+			} else if interactive {
+				t.Variables[p.Name] = lib.Prompt(message, fallback)
+			}
+		}
+	}
+}
+
+func (t *projectTemplate) data(name, gtk string) map[string]any {
+	data := map[string]any{}
+	for key, value := range t.Variables {
+		data[key] = value
+	}
+
+	data["Name"] = name
+	data["Gtk"] = gtk
+	data["AgsJsPackage"] = env.AgsJsPackage
+	return data
+}
+
+func render(name, content string, data map[string]any) string {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(content)
+	if err != nil {
+		lib.Err(err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		lib.Err(err)
+	}
+
+	return buf.String()
+}
+
+// copy the template into the project, rendering files matching Render
+func (t *projectTemplate) copy(s *scaffold, name, gtk string) {
+	data := t.data(name, gtk)
+
+	err := filepath.WalkDir(t.Dir, func(file string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(t.Dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" || (rel != "." && matchAny(t.Ignore, rel)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || rel == templateManifest || matchAny(t.Ignore, rel) {
+			return nil
+		}
+
+		target, ok := templatePath(rel, gtk)
+		if !ok {
+			return nil
+		}
+
+		content := lib.ReadFile(file)
+		if matchAny(t.Render, rel) {
+			content = []byte(render(rel, string(content), data))
+		}
+
+		// leave binary files untouched
+		if !bytes.ContainsRune(content, 0) {
+			content = []byte(substitute(string(content), gtk))
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		s.Write(target, content, info.Mode().Perm()|0644)
+		return nil
+	})
+	if err != nil {
+		lib.Err(err)
+	}
+}
+
+func (t *projectTemplate) postInit(dir, name, gtk string, interactive bool) {
+	if len(t.PostInit) == 0 {
+		return
+	}
+
+	data := t.data(name, gtk)
+	steps := make([]string, len(t.PostInit))
+	for i, step := range t.PostInit {
+		steps[i] = render("postInit", step, data)
+	}
+
+	// the steps come from a third-party template, so they
+	// only run after a confirmation or with --run-post-init
+	if !runPostInit {
+		fmt.Println(lib.Blue("the template wants to run:"))
+		for _, step := range steps {
+			fmt.Println("  " + lib.Yellow(step))
+		}
+
+		if !interactive {
+			fmt.Println(lib.Yellow("skipped: ") + "pass --run-post-init to run them without a prompt")
+			return
+		}
+		if !lib.Confirm("Run post-init steps?", false) {
+			return
+		}
+	}
+
+	for _, step := range steps {
+		fmt.Fprintln(os.Stderr, lib.Blue("executing: ")+step)
+		sh := lib.Exec(env.Bash, "-c", step)
+		sh.Dir = dir
+		sh.Stdout = os.Stdout
+		sh.Stderr = os.Stderr
+		if err := sh.Run(); err != nil {
+			lib.Err("post-init step failed: " + lib.Yellow(step))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"ags/lib"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	paletteOutput string
+	paletteMode   string
+	paletteColors int
+)
+
+var themeCommand = &cobra.Command{
+	Use:   "theme",
+	Short: "Generate color themes",
+}
+
+var themeGenerateCommand = &cobra.Command{
+	Use:   "generate [image]",
+	Short: "Generate a palette from an image",
+	Long: `Generate a light and a dark color scheme from the colors of a png, jpeg or gif image.
+
+The scheme is written as a Sass partial with a map of both schemes and
+variables of the one picked by --mode, which can be used with @use "palette".
+An output file ending with .css defines the colors with @define-color instead.
+Every "on-" color has a contrast of at least 4.5:1 with the color it is drawn on.`,
+	Example: `  ags theme generate ~/wallpaper.png
+  ags theme generate ~/wallpaper.jpg -o style/_palette.scss --mode light`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if paletteMode != "dark" && paletteMode != "light" {
+			lib.Err(`--mode has to be "dark" or "light"`)
+		}
+
+		img, err := lib.LoadImage(args[0])
+		if err != nil {
+			lib.Err(err)
+		}
+
+		swatches := lib.Quantize(img, paletteColors)
+		if len(swatches) == 0 {
+			lib.Err(args[0] + " has no opaque pixels")
+		}
+
+		palette := lib.NewPalette(swatches)
+		source := filepath.Base(args[0])
+		dark := paletteMode == "dark"
+
+		content := palette.Scss(source, dark)
+		if strings.HasSuffix(paletteOutput, ".css") {
+			content = palette.Css(source, dark)
+		}
+
+		if _, err := lib.WriteIfChanged(paletteOutput, content); err != nil {
+			lib.Err(err)
+		}
+
+		scheme := palette.Light
+		if dark {
+			scheme = palette.Dark
+		}
+		fmt.Printf("%s %s\n", lib.Cyan(paletteOutput), paletteMode)
+		for _, role := range scheme {
+			fmt.Printf("  %-20s %s\n", role.Name, role.Color.Hex())
+		}
+	},
+}
+
+func init() {
+	f := themeGenerateCommand.Flags()
+	f.StringVarP(&paletteOutput, "output", "o", "_palette.scss", "file to write the palette to, .scss or .css")
+	f.StringVarP(&paletteMode, "mode", "m", "dark", "scheme of the variables: dark or light")
+	f.IntVar(&paletteColors, "colors", 16, "number of colors the image is reduced to")
+
+	themeCommand.AddCommand(themeGenerateCommand)
+}
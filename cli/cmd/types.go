@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,19 +15,26 @@ import (
 )
 
 var (
-	ignoreModules []string
-	updatePkg     bool
-	verbose       bool
+	ignoreModules  []string
+	updatePkg      bool
+	verbose        bool
+	tsForGir       string
+	typesGenerator string
+	autoTypes      bool
 )
 
 var typesCommand = &cobra.Command{
-	Use:     "types [pattern]",
-	Short:   "Generate TypeScript types",
-	Args:    cobra.MaximumNArgs(1),
-	Example: `  ags types Astal* --ignore Gtk3 --ignore Astal3`,
+	Use:   "types [pattern]",
+	Short: "Generate TypeScript types",
+	Args:  cobra.MaximumNArgs(1),
+	Example: `  ags types Astal* --ignore Gtk3 --ignore Astal3
+  ags types --auto -d /path/to/project`,
 	Run: func(cmd *cobra.Command, args []string) {
+		checkGenerator(typesGenerator)
+
 		if updatePkg {
-			lib.WriteFile(targetDir+"/tsconfig.json", lib.GetTsconfig(targetDir, 0))
+			updateTsconfig(targetDir)
+			syncEditorConfig(targetDir)
 
 			lib.Mkdir(targetDir + "/node_modules")
 			lib.Rm(targetDir + "/node_modules/ags")
@@ -38,10 +47,15 @@ var typesCommand = &cobra.Command{
 			}
 		}
 
-		if len(args) > 0 {
-			genTypes(targetDir, args[0], verbose)
-		} else {
-			genTypes(targetDir, "*", verbose)
+		switch {
+		case autoTypes && len(args) > 0:
+			lib.Err("a pattern can not be used together with --auto")
+		case autoTypes:
+			genTypes(targetDir, importedGirs(targetDir), typesGenerator, verbose)
+		case len(args) > 0:
+			genTypes(targetDir, args[0:1], typesGenerator, verbose)
+		default:
+			genTypes(targetDir, []string{"*"}, typesGenerator, verbose)
 		}
 	},
 }
@@ -49,10 +63,33 @@ var typesCommand = &cobra.Command{
 func init() {
 	f := typesCommand.Flags()
 
-	f.BoolVarP(&verbose, "verbose", "v", false, "print ts-for-gir logs")
+	f.BoolVarP(&verbose, "verbose", "v", false, "print generator logs")
 	f.BoolVarP(&updatePkg, "update", "u", false, "update tsconfig and linked ags package")
+	f.StringArrayVar(&defines, "define", []string{}, "with --update, declare these defines in ags-env.d.ts")
+	f.StringArrayVar(&alias, "alias", []string{}, "with --update, mirror these aliases into tsconfig paths")
 	f.StringVarP(&targetDir, "directory", "d", defaultConfigDir(), "target directory")
 	f.StringArrayVarP(&ignoreModules, "ignore", "i", []string{}, "modules that should be ignored")
+	f.BoolVarP(&autoTypes, "auto", "a", false, "only generate namespaces the project imports")
+	f.StringVar(&typesGenerator, "generator", "ts-for-gir", "type generator to use: ts-for-gir or native")
+	f.StringVar(&tsForGir, "ts-for-gir", "", "path to a local ts-for-gir executable instead of npx")
+}
+
+// sets the compiler options of tsconfig.json in place,
+// which keeps the paths mirrored by syncTsconfigPaths marked
+func updateTsconfig(dir string) {
+	path := filepath.Join(dir, "tsconfig.json")
+	if !lib.FileExists(path) {
+		lib.WriteFile(path, lib.GetTsconfig(dir, 0))
+		return
+	}
+
+	tsconfig, changed, err := lib.UpdateTsconfig(lib.ReadFile(path), 0)
+	if err != nil {
+		lib.Err(path + ": " + err.Error())
+	}
+	if changed {
+		lib.WriteFile(path, string(tsconfig))
+	}
 }
 
 func spinner(stopChan chan bool) {
@@ -80,50 +117,225 @@ func showCursor() {
 	fmt.Print("\033[?25h")
 }
 
-func genTypes(configDir, pattern string, verbose bool) {
+// a locally installed ts-for-gir, empty when npx should be used
+func localTsForGir() string {
+	bin := tsForGir
+	if bin == "" && filepath.IsAbs(env.TSForGir) {
+		bin = env.TSForGir
+	}
+	if bin == "" {
+		bin, _ = exec.LookPath("ts-for-gir")
+	}
+	if bin != "" && !lib.FileExists(bin) {
+		lib.Err("no such file: " + lib.Cyan(bin))
+	}
+	return bin
+}
+
+func tsForGirCommand(bin string, args ...string) *exec.Cmd {
+	if bin != "" {
+		return exec.Command(bin, args...)
+	}
+	return lib.Exec("npx", append([]string{"-y", env.TSForGir}, args...)...)
+}
+
+// matches a name like "Gtk-3.0" by namespace ("Gtk"), name or namespace
+// with the major version ("Gtk3")
+func matchGir(gir lib.GirFile, pattern string) bool {
+	major, _, _ := strings.Cut(gir.Version, ".")
+	for _, name := range []string{gir.Namespace, gir.Name(), gir.Namespace + major} {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the names of namespaces matching any of the patterns
+func matchGirs(girs map[string]lib.GirFile, patterns []string) []string {
+	var names []string
+	for name, gir := range girs {
+		if slices.ContainsFunc(patterns, func(p string) bool { return matchGir(gir, p) }) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// returns the names of namespaces imported in the module graph of the project,
+// unversioned imports resolve to the latest available version
+func importedGirs(dir string) []string {
+	result := lib.Bundle(lib.BundleOpts{
+		Infile:           getAppEntry(dir),
+		WorkingDirectory: dir,
+		Metafile:         true,
+		SkipTypelibCheck: true,
+	})
+
+	girs := lib.FindGirs()
+	var names []string
+	for _, imp := range lib.ParseMetafile(result.Metafile).GiImports() {
+		name := imp.Namespace + "-" + imp.Version
+		if imp.Version == "" {
+			name = latestGir(girs, imp.Namespace)
+		}
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if verbose {
+		fmt.Fprintln(os.Stderr, lib.Blue("imported: ")+strings.Join(names, " "))
+	}
+
+	return names
+}
+
+func latestGir(girs map[string]lib.GirFile, namespace string) string {
+	var latest *lib.GirFile
+	for _, gir := range girs {
+		if gir.Namespace == namespace && (latest == nil || lib.CompareVersions(gir.Version, latest.Version) > 0) {
+			latest = &gir
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Name()
+}
+
+// checks the generator flags before anything is written
+func checkGenerator(generator string) {
+	switch {
+	case generator != "ts-for-gir" && generator != "native":
+		lib.Err("unknown generator " + lib.Cyan(generator) + "\n" +
+			lib.Cyan("tip: ") + "valid generators are: ts-for-gir, native")
+	case generator == "native" && tsForGir != "":
+		lib.Err("--ts-for-gir can not be used together with --generator native")
+	}
+}
+
+func genTypes(configDir string, patterns []string, generator string, verbose bool) {
 	lib.Mkdir(configDir)
 
-	npx, err := exec.LookPath("npx")
-	if err != nil {
-		lib.Err(err)
+	girs := lib.FindGirs()
+	ignored := matchGirs(girs, ignoreModules)
+	for _, name := range ignored {
+		delete(girs, name)
 	}
 
-	flags := []string{
-		"-y", env.TSForGir, "generate", pattern,
-		"--ignoreVersionConflicts",
-		"--outdir", filepath.Join(configDir, "@girs"),
+	names := lib.GirClosure(girs, matchGirs(girs, patterns))
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, lib.Yellow("warning: ")+"no GIR files found matching "+
+			lib.Cyan(strings.Join(patterns, " ")))
+		return
 	}
 
-	dataDirs := append([]string{
-		"/usr/local/share/gir-1.0",
-		"/usr/share/gir-1.0",
-		"/usr/share/*/gir-1.0",
-	}, strings.Split(os.Getenv("EXTRA_GIR_DIRS"), ":")...)
+	outdir, cleanup := lib.TempDir("ags-girs-")
+	defer cleanup()
+
+	var cache *lib.GirCache
+	var run func(stale []string) error
+	var command string
+
+	switch {
+	case generator == "ts-for-gir":
+		bin := localTsForGir()
+		if bin == "" {
+			cache = lib.OpenGirCache("npx " + env.TSForGir)
+		} else {
+			cache = lib.OpenGirCache(bin)
+		}
+
+		run = func(stale []string) error {
+			flags := append([]string{"generate"}, stale...)
+			flags = append(flags,
+				"--ignoreVersionConflicts",
+				"--outdir", outdir,
+			)
+
+			for _, path := range lib.GirDirPatterns() {
+				flags = append(flags, "-g", path)
+			}
+
+			if len(ignored) > 0 {
+				flags = append(append(flags, "--ignore"), ignored...)
+			}
+
+			cmd := tsForGirCommand(bin, flags...)
+			command = strings.Join(cmd.Args, " ")
+
+			if verbose {
+				fmt.Fprintln(os.Stderr, lib.Blue("executing: ")+command)
+				cmd.Stderr = os.Stderr
+				cmd.Stdout = os.Stdout
+			}
 
-	for _, path := range dataDirs {
-		flags = append(flags, "-g", path)
+			return cmd.Run()
+		}
+	case generator == "native":
+		cache = lib.OpenGirCache("native " + env.Version)
+
+		run = func(stale []string) error {
+			lib.WriteFile(filepath.Join(outdir, "gjs.d.ts"), getDataFile("gjs.d.ts"))
+
+			for _, name := range stale {
+				if verbose {
+					fmt.Fprintln(os.Stderr, lib.Blue("generating: ")+name)
+				}
+
+				dts, err := lib.GenerateDts(girs, name, unversioned(girs, name))
+				if err != nil {
+					return err
+				}
+
+				lib.WriteFile(filepath.Join(outdir, strings.ToLower(name)+".d.ts"), dts)
+			}
+			return nil
+		}
+	default:
+		lib.Err("unknown generator " + lib.Cyan(generator) + "\n" +
+			lib.Cyan("tip: ") + "valid generators are: ts-for-gir, native")
 	}
 
-	hideCursor()
+	if stale := cache.Stale(girs, names); len(stale) > 0 {
+		hideCursor()
 
-	cmd := exec.Command(npx, flags...)
+		var err error
+		if verbose {
+			err = run(stale)
+		} else {
+			stopChan := make(chan bool)
+			go spinner(stopChan)
+			err = run(stale)
+			stopChan <- true
+		}
 
-	if verbose {
-		fmt.Fprintln(os.Stderr, lib.Blue("executing: ")+strings.Join(cmd.Args, " "))
-
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
-		err = cmd.Run()
-	} else {
-		stopChan := make(chan bool)
-		go spinner(stopChan)
-		err = cmd.Run()
-		stopChan <- true
 		showCursor()
+
+		if err != nil && command != "" {
+			lib.Err("type generation failed, try running\n" + lib.Yellow(command))
+		} else if err != nil {
+			lib.Err(err)
+		}
+
+		stored := cache.Store(girs, outdir)
+		if verbose {
+			fmt.Fprintf(os.Stderr, "%s %d namespaces, %d from cache\n",
+				lib.Blue("generated"), len(stored), len(names)-len(stale))
+		}
 	}
 
-	if err != nil {
-		lib.Err("type generation failed, try running\n" +
-			lib.Yellow(npx+" "+strings.Join(flags, " ")))
+	cache.Save()
+	cache.Restore(names, filepath.Join(configDir, "@girs"))
+}
+
+// whether name is the only version of its namespace
+func unversioned(girs map[string]lib.GirFile, name string) bool {
+	for other, gir := range girs {
+		if other != name && gir.Namespace == girs[name].Namespace {
+			return false
+		}
 	}
+	return true
 }
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"ags/lib"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	entries  []string
+	programs []string
+)
+
+// program is one of the entry points of a workspace,
+// each one is started as a separate gjs process
+type program struct {
+	Name     string
+	Entry    string
+	Instance string
+	Gtk      uint
+}
+
+// name of a program without configuration, "app" entries are named after their directory
+func programName(entry string) string {
+	name := strings.TrimSuffix(filepath.Base(entry), filepath.Ext(entry))
+	if name == "app" {
+		return filepath.Base(filepath.Dir(entry))
+	}
+	return name
+}
+
+// absolute path of an entry file, directories resolve to their "app" entry
+func resolveEntry(path, dir string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		lib.Err(err)
+	}
+
+	if info.IsDir() {
+		return getAppEntry(path)
+	}
+	return path
+}
+
+// programs of the workspace in root, explicit entries take precedence
+// over the ones declared in ags.json, names filters them by name
+func workspacePrograms(root string, entries []string, names []string) []program {
+	var progs []program
+
+	if len(entries) > 0 {
+		for _, entry := range entries {
+			path := resolveEntry(entry, lib.Cwd())
+			name := programName(path)
+			progs = append(progs, program{Name: name, Entry: path, Instance: name})
+		}
+	} else {
+		config := lib.LoadProjectConfig(root)
+		for name, value := range config.Programs {
+			p := program{Name: name, Instance: name}
+
+			switch v := value.(type) {
+			case string:
+				p.Entry = v
+			case map[string]any:
+				p.Entry, _ = v["entry"].(string)
+				if instance, ok := v["instance"].(string); ok {
+					p.Instance = instance
+				}
+			}
+
+			if p.Entry == "" {
+				lib.Err(fmt.Sprintf(`%s: program "%s" has no entry`, lib.ProjectConfigFile, name))
+			}
+
+			p.Entry = resolveEntry(p.Entry, root)
+			progs = append(progs, p)
+		}
+	}
+
+	if len(names) > 0 {
+		for _, name := range names {
+			if !slices.ContainsFunc(progs, func(p program) bool { return p.Name == name }) {
+				lib.Err(`no program named "` + name + `"`)
+			}
+		}
+		progs = slices.DeleteFunc(progs, func(p program) bool {
+			return !slices.Contains(names, p.Name)
+		})
+	}
+
+	slices.SortFunc(progs, func(a, b program) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for i := range progs {
+		if i > 0 && progs[i].Name == progs[i-1].Name {
+			lib.Err(`multiple programs are named "` + progs[i].Name + `"`)
+		}
+		progs[i].Gtk = inferGtkVersion(progs[i].Entry, root)
+	}
+
+	return progs
+}
+
+// programs grouped by their gtk version, since the jsx runtime
+// depends on it each group has to be bundled on its own
+func programsByGtk(progs []program) map[uint][]program {
+	groups := map[uint][]program{}
+	for _, p := range progs {
+		groups[p.Gtk] = append(groups[p.Gtk], p)
+	}
+	return groups
+}
+
+func bundleEntries(progs []program) []lib.Entry {
+	entries := make([]lib.Entry, len(progs))
+	for i, p := range progs {
+		entries[i] = lib.Entry{Name: p.Name, Path: p.Entry}
+	}
+	return entries
+}
+
+// prefixWriter prefixes every line written to it
+type prefixWriter struct {
+	w       io.Writer
+	prefix  []byte
+	midline bool
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	n := len(data)
+	var out []byte
+	for len(data) > 0 {
+		if !p.midline {
+			out = append(out, p.prefix...)
+		}
+
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			out = append(out, data...)
+			p.midline = true
+			break
+		}
+
+		out = append(out, data[:i+1]...)
+		data = data[i+1:]
+		p.midline = false
+	}
+
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
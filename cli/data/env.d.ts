@@ -5,6 +5,18 @@ declare module "inline:*" {
   export default content
 }
 
+declare module "*.module.scss" {
+  const classes: Record<string, string>
+  export const css: string
+  export default classes
+}
+
+declare module "*.module.css" {
+  const classes: Record<string, string>
+  export const css: string
+  export default classes
+}
+
 declare module "*.scss" {
   const content: string
   export default content
@@ -19,3 +31,93 @@ declare module "*.css" {
   const content: string
   export default content
 }
+
+declare module "*.json5" {
+  const data: any
+  export default data
+}
+
+declare module "*.yaml" {
+  const data: any
+  export default data
+}
+
+declare module "*.yml" {
+  const data: any
+  export default data
+}
+
+declare module "*.toml" {
+  const data: Record<string, any>
+  export default data
+}
+
+declare module "*.png" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.jpg" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.jpeg" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.gif" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.webp" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.wav" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.ogg" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.mp3" {
+  const data: Uint8Array
+  export default data
+}
+
+declare module "*.ttf" {
+  /** family name of the font, the file is shipped with the bundle */
+  const family: string
+  export default family
+}
+
+declare module "*.otf" {
+  /** family name of the font, the file is shipped with the bundle */
+  const family: string
+  export default family
+}
+
+declare module "*.ttc" {
+  /** family name of the font, the file is shipped with the bundle */
+  const family: string
+  export default family
+}
+
+declare module "*.woff" {
+  /** family name of the font, the file is shipped with the bundle */
+  const family: string
+  export default family
+}
+
+declare module "*.woff2" {
+  /** family name of the font, the file is shipped with the bundle */
+  const family: string
+  export default family
+}
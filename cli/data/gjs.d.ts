@@ -0,0 +1,59 @@
+// @ts-nocheck
+// generated by ags
+
+declare function print(...args: any[]): void
+declare function printerr(...args: any[]): void
+declare function log(message: any): void
+declare function logError(exception: unknown, message?: any): void
+
+declare const imports: any
+
+declare module "system" {
+    export const programInvocationName: string
+    export const programArgs: string[]
+    export const programPath: string | null
+    export const version: number
+    export function exit(code: number): never
+    export function gc(): void
+    export function breakpoint(): void
+}
+
+declare module "console" {
+    export const DEFAULT_LOG_DOMAIN: string
+    export function setConsoleLogDomain(domain: string): void
+    export function getConsoleLogDomain(): string
+}
+
+declare module "gettext" {
+    export enum LocaleCategory {
+        ALL,
+        COLLATE,
+        CTYPE,
+        MESSAGES,
+        MONETARY,
+        NUMERIC,
+        TIME,
+    }
+
+    export function setlocale(category: LocaleCategory, locale: string | null): string
+    export function textdomain(domain: string): void
+    export function bindtextdomain(domain: string, location: string): void
+    export function gettext(msgid: string): string
+    export function dgettext(domain: string, msgid: string): string
+    export function dcgettext(domain: string, msgid: string, category: LocaleCategory): string
+    export function ngettext(msgid1: string, msgid2: string, n: number): string
+    export function dngettext(domain: string, msgid1: string, msgid2: string, n: number): string
+    export function pgettext(context: string, msgid: string): string
+    export function dpgettext(domain: string, context: string, msgid: string): string
+
+    export function domain(domain: string): {
+        gettext(msgid: string): string
+        ngettext(msgid1: string, msgid2: string, n: number): string
+        pgettext(context: string, msgid: string): string
+    }
+}
+
+declare module "cairo" {
+    const cairo: any
+    export default cairo
+}
@@ -0,0 +1,7 @@
+import app from "ags/gtk3/app"
+import style from "./style.scss"
+
+app.start({
+  css: style,
+  main() {},
+})
@@ -0,0 +1,11 @@
+import app from "ags/gtk3/app"
+import style from "./style.scss"
+import Launcher from "./widget/Launcher"
+
+// toggle it with `ags toggle launcher`
+app.start({
+  css: style,
+  main() {
+    Launcher()
+  },
+})
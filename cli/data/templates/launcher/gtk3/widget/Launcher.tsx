@@ -0,0 +1,62 @@
+import app from "ags/gtk3/app"
+import { For, createState } from "ags"
+import { Astal, Gtk, Gdk } from "ags/gtk3"
+import AstalApps from "gi://AstalApps"
+
+export default function Launcher() {
+  let searchentry: Gtk.Entry
+  let win: Astal.Window
+
+  const apps = new AstalApps.Apps()
+  const [list, setList] = createState(new Array<AstalApps.Application>())
+
+  function search(text: string) {
+    if (text === "") setList([])
+    else setList(apps.fuzzy_query(text).slice(0, 8))
+  }
+
+  function launch(app?: AstalApps.Application) {
+    if (app) {
+      win.hide()
+      app.launch()
+    }
+  }
+
+  return (
+    <window
+      $={(ref) => (win = ref)}
+      name="launcher"
+      class="Launcher"
+      application={app}
+      keymode={Astal.Keymode.EXCLUSIVE}
+      onKeyPressEvent={(self, event: Gdk.Event) => {
+        if (event.get_keyval()[1] === Gdk.KEY_Escape) self.hide()
+      }}
+      onNotifyVisible={({ visible }) => {
+        if (visible) searchentry.grab_focus()
+        else searchentry.set_text("")
+      }}
+    >
+      <box vertical>
+        <entry
+          $={(ref) => (searchentry = ref)}
+          onNotifyText={({ text }) => search(text)}
+          onActivate={() => launch(list.get()[0])}
+          placeholderText="Start typing to search"
+        />
+        <box vertical>
+          <For each={list}>
+            {(app) => (
+              <button onClicked={() => launch(app)}>
+                <box>
+                  <icon icon={app.iconName} />
+                  <label label={app.name} maxWidthChars={40} wrap />
+                </box>
+              </button>
+            )}
+          </For>
+        </box>
+      </box>
+    </window>
+  )
+}
@@ -0,0 +1,66 @@
+import app from "ags/gtk4/app"
+import { For, createState } from "ags"
+import { Astal, Gtk, Gdk } from "ags/gtk4"
+import AstalApps from "gi://AstalApps"
+
+export default function Launcher() {
+  let searchentry: Gtk.Entry
+  let win: Astal.Window
+
+  const apps = new AstalApps.Apps()
+  const [list, setList] = createState(new Array<AstalApps.Application>())
+
+  function search(text: string) {
+    if (text === "") setList([])
+    else setList(apps.fuzzy_query(text).slice(0, 8))
+  }
+
+  function launch(app?: AstalApps.Application) {
+    if (app) {
+      win.hide()
+      app.launch()
+    }
+  }
+
+  function onKey(_e: Gtk.EventControllerKey, keyval: number) {
+    if (keyval === Gdk.KEY_Escape) {
+      win.visible = false
+    }
+  }
+
+  return (
+    <window
+      $={(ref) => (win = ref)}
+      name="launcher"
+      class="Launcher"
+      application={app}
+      keymode={Astal.Keymode.EXCLUSIVE}
+      onNotifyVisible={({ visible }) => {
+        if (visible) searchentry.grab_focus()
+        else searchentry.set_text("")
+      }}
+    >
+      <Gtk.EventControllerKey onKeyPressed={onKey} />
+      <box orientation={Gtk.Orientation.VERTICAL}>
+        <entry
+          $={(ref) => (searchentry = ref)}
+          onNotifyText={({ text }) => search(text)}
+          onActivate={() => launch(list.get()[0])}
+          placeholderText="Start typing to search"
+        />
+        <box orientation={Gtk.Orientation.VERTICAL}>
+          <For each={list}>
+            {(app) => (
+              <button onClicked={() => launch(app)}>
+                <box>
+                  <image iconName={app.iconName} />
+                  <label label={app.name} maxWidthChars={40} wrap />
+                </box>
+              </button>
+            )}
+          </For>
+        </box>
+      </box>
+    </window>
+  )
+}
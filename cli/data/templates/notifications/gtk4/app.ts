@@ -0,0 +1,10 @@
+import app from "ags/gtk4/app"
+import style from "./style.scss"
+import NotificationPopups from "./widget/NotificationPopups"
+
+app.start({
+  css: style,
+  main() {
+    NotificationPopups()
+  },
+})
@@ -0,0 +1,54 @@
+import { Gtk } from "ags/gtk4"
+import AstalNotifd from "gi://AstalNotifd"
+import Pango from "gi://Pango"
+
+interface NotificationProps {
+  notification: AstalNotifd.Notification
+}
+
+export default function Notification({ notification: n }: NotificationProps) {
+  return (
+    <box
+      widthRequest={400}
+      class="Notification"
+      orientation={Gtk.Orientation.VERTICAL}
+    >
+      <box class="header">
+        <label
+          class="app-name"
+          hexpand
+          halign={Gtk.Align.START}
+          ellipsize={Pango.EllipsizeMode.END}
+          label={n.appName || "Unknown"}
+        />
+        <button onClicked={() => n.dismiss()}>
+          <image iconName="window-close-symbolic" />
+        </button>
+      </box>
+      <box class="content" orientation={Gtk.Orientation.VERTICAL}>
+        <label
+          class="summary"
+          halign={Gtk.Align.START}
+          ellipsize={Pango.EllipsizeMode.END}
+          label={n.summary}
+        />
+        <label
+          class="body"
+          visible={n.body !== ""}
+          wrap
+          useMarkup
+          halign={Gtk.Align.START}
+          xalign={0}
+          label={n.body}
+        />
+      </box>
+      <box class="actions" visible={n.actions.length > 0}>
+        {n.actions.map(({ label, id }) => (
+          <button hexpand onClicked={() => n.invoke(id)}>
+            <label label={label} />
+          </button>
+        ))}
+      </box>
+    </box>
+  )
+}
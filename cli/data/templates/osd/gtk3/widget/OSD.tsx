@@ -0,0 +1,40 @@
+import app from "ags/gtk3/app"
+import { Astal, Gtk, Gdk } from "ags/gtk3"
+import { createBinding, createState, onCleanup } from "ags"
+import { timeout, Timer } from "ags/time"
+import AstalWp from "gi://AstalWp"
+
+export default function OSD(gdkmonitor: Gdk.Monitor) {
+  const speaker = AstalWp.get_default()!.defaultSpeaker
+  const [visible, setVisible] = createState(false)
+  let timer: Timer | undefined
+
+  // show the osd on volume changes and hide it after a second
+  const handler = speaker.connect("notify::volume", () => {
+    setVisible(true)
+    timer?.cancel()
+    timer = timeout(1000, () => setVisible(false))
+  })
+
+  onCleanup(() => speaker.disconnect(handler))
+
+  return (
+    <window
+      class="OSD"
+      gdkmonitor={gdkmonitor}
+      visible={visible}
+      layer={Astal.Layer.OVERLAY}
+      anchor={Astal.WindowAnchor.BOTTOM}
+      application={app}
+    >
+      <box>
+        <icon icon={createBinding(speaker, "volumeIcon")} />
+        <levelbar
+          valign={Gtk.Align.CENTER}
+          widthRequest={160}
+          value={createBinding(speaker, "volume")}
+        />
+      </box>
+    </window>
+  )
+}
@@ -0,0 +1,10 @@
+import app from "ags/gtk4/app"
+import style from "./style.scss"
+import OSD from "./widget/OSD"
+
+app.start({
+  css: style,
+  main() {
+    app.get_monitors().map(OSD)
+  },
+})
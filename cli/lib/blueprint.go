@@ -0,0 +1,308 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+var (
+	ansiEscape    = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	blpDiagnostic = regexp.MustCompile(`(?m)^(\w+): (.*)\nat (.+) line (\d+) column (\d+):`)
+	blpHint       = regexp.MustCompile(`^hint: (.*)`)
+)
+
+// blpDiagnostics parses the errors and warnings blueprint-compiler prints, e.g
+//
+//	error: Could not find object with ID box
+//	at /path/to/window.blp line 12 column 5:
+//	  12 |    child: box;
+//	     |           ^
+//	hint: did you mean "Box"?
+func blpDiagnostics(output string) (map[string][]api.Message, map[string][]api.Message) {
+	errors := map[string][]api.Message{}
+	warnings := map[string][]api.Message{}
+
+	output = ansiEscape.ReplaceAllString(output, "")
+	matches := blpDiagnostic.FindAllStringSubmatchIndex(output, -1)
+
+	for i, m := range matches {
+		category, text, file := output[m[2]:m[3]], output[m[4]:m[5]], output[m[6]:m[7]]
+		line, _ := strconv.Atoi(output[m[8]:m[9]])
+		col, _ := strconv.Atoi(output[m[10]:m[11]])
+
+		file, _ = filepath.Abs(file)
+		msg := api.Message{
+			Text:     text,
+			Location: &api.Location{File: file, Line: line, Column: max(col-1, 0)},
+		}
+
+		if data, err := os.ReadFile(file); err == nil {
+			msg.Location.LineText = lineText(string(data), line)
+		}
+
+		end := len(output)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		for _, l := range strings.Split(output[m[1]:end], "\n") {
+			if hint := blpHint.FindStringSubmatch(strings.TrimSpace(l)); hint != nil {
+				msg.Notes = append(msg.Notes, api.Note{Text: hint[1]})
+			}
+		}
+
+		if category == "error" {
+			errors[file] = append(errors[file], msg)
+		} else {
+			warnings[file] = append(warnings[file], msg)
+		}
+	}
+
+	return errors, warnings
+}
+
+// blpBatch is the output of compiling every blueprint file of a project at once
+type blpBatch struct {
+	once     sync.Once
+	dir      string
+	cleanup  func()
+	files    []string
+	warnings map[string][]api.Message
+	ok       bool
+}
+
+func (b *blpBatch) compile(root string) {
+	b.files = globFiles(root, []string{"*.blp"})
+	if len(b.files) == 0 {
+		return
+	}
+
+	b.dir, b.cleanup = TempDir("ags-blueprint-")
+
+	var stderr bytes.Buffer
+	blp := Exec("blueprint-compiler", append([]string{"batch-compile", b.dir, root}, b.files...)...)
+	blp.Stderr = &stderr
+
+	// files with errors are compiled again on their own when they are loaded
+	// so that the build reports every error, not just the first
+	if blp.Run() == nil {
+		_, b.warnings = blpDiagnostics(stderr.String())
+		b.ok = true
+	}
+}
+
+// the compiled ui of file if it was part of a successful batch
+func (b *blpBatch) output(root, file string) (string, []api.Message, bool) {
+	if !b.ok || !slices.Contains(b.files, file) {
+		return "", nil, false
+	}
+
+	rel, err := filepath.Rel(root, strings.TrimSuffix(file, ".blp")+".ui")
+	if err != nil {
+		return "", nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(b.dir, rel))
+	if err != nil {
+		return "", nil, false
+	}
+
+	return strings.TrimSpace(string(data)), b.warnings[file], true
+}
+
+var (
+	blpUsing  = regexp.MustCompile(`(?m)^\s*using\s+(\w+)\s+([\d.]+)\s*;`)
+	blpObject = regexp.MustCompile(`(\$?[A-Za-z_][\w]*(?:\.[A-Za-z_][\w]*)?)\s+([A-Za-z_][\w]*)\s*\{`)
+	blpIgnore = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/|"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`)
+)
+
+// keywords which declare a Gio.Menu
+var blpMenus = []string{"menu", "submenu", "section"}
+
+type blpObjectId struct {
+	Id   string
+	Type string
+}
+
+// namespaces and object ids declared in a blueprint file
+func blpObjects(source string) (map[string]string, []blpObjectId) {
+	namespaces := map[string]string{"Gtk": "4.0"}
+	for _, m := range blpUsing.FindAllStringSubmatch(source, -1) {
+		namespaces[m[1]] = m[2]
+	}
+
+	source = blpIgnore.ReplaceAllString(source, `""`)
+
+	var objects []blpObjectId
+	for _, m := range blpObject.FindAllStringSubmatch(source, -1) {
+		class, id := m[1], m[2]
+		switch {
+		case slices.Contains(blpMenus, class):
+			class = "Gio.Menu"
+			namespaces["Gio"] = "2.0"
+		case strings.HasPrefix(class, "$"):
+			// types registered at runtime can not be typed
+			class = "GObject.Object"
+			namespaces["GObject"] = "2.0"
+		case !strings.Contains(class, "."):
+			if class[0] < 'A' || class[0] > 'Z' {
+				continue
+			}
+			class = "Gtk." + class
+		}
+
+		if !slices.ContainsFunc(objects, func(o blpObjectId) bool { return o.Id == id }) {
+			objects = append(objects, blpObjectId{id, class})
+		}
+	}
+
+	return namespaces, objects
+}
+
+// writes "<file>.d.ts" next to the blueprint file typing the objects of its ids
+func writeBlpDts(file string, source string) {
+	namespaces, objects := blpObjects(source)
+
+	var used []string
+	for _, o := range objects {
+		ns, _, _ := strings.Cut(o.Type, ".")
+		if !slices.Contains(used, ns) {
+			used = append(used, ns)
+		}
+	}
+	if !slices.Contains(used, "Gtk") {
+		used = append(used, "Gtk")
+	}
+	slices.Sort(used)
+
+	var dts strings.Builder
+	dts.WriteString("// generated by ags from " + filepath.Base(file) + "\n")
+	for _, ns := range used {
+		dts.WriteString(fmt.Sprintf("import type %s from \"gi://%s?version=%s\"\n", ns, ns, namespaces[ns]))
+	}
+
+	dts.WriteString("\nexport interface Objects {\n")
+	for _, o := range objects {
+		dts.WriteString(fmt.Sprintf("  %s: %s\n", o.Id, o.Type))
+	}
+	dts.WriteString("}\n\n")
+
+	dts.WriteString("/** a Gtk.Builder created from this file */\n")
+	dts.WriteString("export type Builder = Omit<Gtk.Builder, \"get_object\"> & {\n")
+	dts.WriteString("  get_object<K extends keyof Objects>(name: K): Objects[K]\n")
+	dts.WriteString("}\n\n")
+
+	dts.WriteString("/** members gjs defines for the InternalChildren of a template */\n")
+	dts.WriteString("export type TemplateChildren = {\n")
+	dts.WriteString("  [K in keyof Objects as `_${K}`]: Objects[K]\n")
+	dts.WriteString("}\n\n")
+
+	dts.WriteString("declare const ui: string\n")
+	dts.WriteString("export default ui\n")
+
+	writeSourceDts(file+".d.ts", dts.String())
+}
+
+// typelibs of the namespaces a blueprint file is "using"
+func blpWatchFiles(typelibs map[string]GirFile, source string) []string {
+	var files []string
+	for _, m := range blpUsing.FindAllStringSubmatch(source, -1) {
+		if typelib, ok := FindTypelib(typelibs, m[1], m[2]); ok {
+			files = append(files, typelib.Path)
+		}
+	}
+	return files
+}
+
+// blpPlugin compiles the blueprint files of the project in a single batch
+// on the first import of one, files outside of the project are compiled on their own
+func blpPlugin() api.Plugin {
+	batch := &blpBatch{}
+	typelibs := sync.OnceValue(FindTypelibs)
+
+	return api.Plugin{
+		Name: "blueprint",
+		Setup: func(build api.PluginBuild) {
+			root := build.InitialOptions.AbsWorkingDir
+			if root == "" {
+				root = Cwd()
+			}
+
+			build.OnResolve(api.OnResolveOptions{Filter: `.*\.blp$`},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					if isResolving(args) {
+						return api.OnResolveResult{}, nil
+					}
+
+					result, ok := resolvePath(build, args, args.Path)
+					if ok {
+						result.Namespace = "blueprint"
+					}
+					return result, nil
+				},
+			)
+
+			build.OnLoad(api.OnLoadOptions{Filter: `.*\.blp$`, Namespace: "blueprint"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					source, err := os.ReadFile(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+
+					writeBlpDts(args.Path, string(source))
+					watchFiles := append([]string{args.Path}, blpWatchFiles(typelibs(), string(source))...)
+
+					batch.once.Do(func() { batch.compile(root) })
+					if content, warnings, ok := batch.output(root, args.Path); ok {
+						return api.OnLoadResult{
+							Contents:   &content,
+							WatchFiles: watchFiles,
+							Loader:     api.LoaderText,
+							Warnings:   warnings,
+						}, nil
+					}
+
+					var stderr bytes.Buffer
+					blp := Exec("blueprint-compiler", "compile", args.Path)
+					blp.Stderr = &stderr
+
+					data, err := blp.Output()
+					errors, warnings := blpDiagnostics(stderr.String())
+					if err != nil {
+						if len(errors[args.Path]) == 0 {
+							os.Stderr.Write(stderr.Bytes())
+							return api.OnLoadResult{}, err
+						}
+						return api.OnLoadResult{
+							Errors:     errors[args.Path],
+							Warnings:   warnings[args.Path],
+							WatchFiles: watchFiles,
+						}, nil
+					}
+
+					content := strings.TrimSpace(string(data))
+					return api.OnLoadResult{
+						Contents:   &content,
+						WatchFiles: watchFiles,
+						Loader:     api.LoaderText,
+						Warnings:   warnings[args.Path],
+					}, nil
+				})
+
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				if batch.cleanup != nil {
+					batch.cleanup()
+				}
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"path/filepath"
+
+	"github.com/titanous/json5"
+)
+
+// ProjectConfigFile is the optional configuration in the root of a project
+const ProjectConfigFile = "ags.json"
+
+// ProjectConfig is the content of ags.json, which is parsed as JSON5
+type ProjectConfig struct {
+	// programs of a workspace keyed by their name, a value is either
+	// the path of the entry or an object with "entry" and "instance"
+	Programs map[string]any `json:"programs"`
+
+	// external commands which turn files into modules
+	Loaders []ExecLoader `json:"loaders"`
+
+	// gettext domain of the translations in po/, defaults to the name of the project directory
+	Domain string `json:"domain"`
+
+	// directory of loose icons which are assembled into an icon theme, defaults to icons/
+	Icons string `json:"icons"`
+}
+
+// LoadProjectConfig reads the config of the project in dir,
+// a missing config is the same as an empty one
+func LoadProjectConfig(dir string) ProjectConfig {
+	var config ProjectConfig
+
+	path := filepath.Join(dir, ProjectConfigFile)
+	if !FileExists(path) {
+		return config
+	}
+
+	if err := json5.Unmarshal(ReadFile(path), &config); err != nil {
+		Err(path + ": " + err.Error())
+	}
+
+	return config
+}
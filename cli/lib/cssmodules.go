@@ -0,0 +1,265 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// cssModulePlugin scopes the class names of .module.scss and .module.css files,
+// the module exports the mapping of class names by default and the css as "css"
+func cssModulePlugin(check gtkCss) api.Plugin {
+	return api.Plugin{
+		Name: "css-module",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: `\.module\.s?css$`},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					if isResolving(args) {
+						return api.OnResolveResult{}, nil
+					}
+
+					result, ok := resolvePath(build, args, args.Path)
+					if ok {
+						result.Namespace = "css-module"
+					}
+					return result, nil
+				},
+			)
+
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: "css-module"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					source, err := os.ReadFile(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+
+					compiled := sassResult{Css: string(source), Files: []string{args.Path}}
+					if strings.HasSuffix(args.Path, ".scss") {
+						if compiled, err = compileSass(args.Path); err != nil {
+							return api.OnLoadResult{}, err
+						}
+					}
+
+					css := compiled.Css
+					warnings, errors := check.messages(css, args.Path, compiled.SourceMap)
+
+					scoped, classes := scopeCss(css, cssModulePrefix(args.Path, source))
+					writeCssModuleDts(args.Path, classes)
+
+					mapping, err := json.Marshal(classes)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+
+					content := fmt.Sprintf("export const css = %s\nexport default %s\n", jsString(scoped), mapping)
+					return api.OnLoadResult{
+						Contents:   &content,
+						WatchFiles: compiled.Files,
+						Loader:     api.LoaderJS,
+						Warnings:   warnings,
+						Errors:     errors,
+					}, nil
+				})
+		},
+	}
+}
+
+func jsString(s string) string {
+	out, _ := json.Marshal(s)
+	return string(out)
+}
+
+// scoped names are "<file>_<class>_<hash>" where the hash only depends on the name
+// and content of the stylesheet so builds from any directory agree on the names
+func cssModulePrefix(file string, source []byte) func(string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	name = strings.TrimSuffix(name, ".module")
+	name = regexp.MustCompile(`[^a-zA-Z0-9_-]`).ReplaceAllString(name, "_")
+
+	sum := sha256.Sum256(append([]byte(filepath.Base(file)+"\x00"), source...))
+	hash := hex.EncodeToString(sum[:])[:6]
+
+	return func(class string) string {
+		return name + "_" + class + "_" + hash
+	}
+}
+
+// scopeCss renames the class selectors of css, selectors inside :global() are kept,
+// returns the css and the mapping from original to scoped names
+func scopeCss(css string, scope func(string) string) (string, map[string]string) {
+	classes := map[string]string{}
+	var out strings.Builder
+	scopeRules(css, scope, classes, &out)
+	return out.String(), classes
+}
+
+// copies a list of rules, rewriting selectors and leaving declarations untouched
+func scopeRules(css string, scope func(string) string, classes map[string]string, out *strings.Builder) {
+	for len(css) > 0 {
+		end := cssIndexAny(css, "{;}")
+		if end == -1 {
+			out.WriteString(css)
+			return
+		}
+
+		prelude := css[:end]
+		if css[end] != '{' {
+			// statements like @import or @define-color
+			out.WriteString(css[:end+1])
+			css = css[end+1:]
+			continue
+		}
+
+		close := cssMatchingBrace(css, end)
+		block := css[end+1 : close]
+		trimmed := strings.TrimSpace(prelude)
+
+		switch {
+		case strings.HasPrefix(trimmed, "@media"), strings.HasPrefix(trimmed, "@supports"):
+			out.WriteString(prelude + "{")
+			scopeRules(block, scope, classes, out)
+			out.WriteString("}")
+		case strings.HasPrefix(trimmed, "@"):
+			out.WriteString(css[:close+1])
+		default:
+			out.WriteString(scopeSelector(prelude, scope, classes) + "{" + block + "}")
+		}
+
+		css = css[close+1:]
+	}
+}
+
+var cssIdent = regexp.MustCompile(`^-?[_a-zA-Z][_a-zA-Z0-9-]*`)
+
+func scopeSelector(selector string, scope func(string) string, classes map[string]string) string {
+	var out strings.Builder
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := cssStringEnd(selector, i)
+			out.WriteString(selector[i:end])
+			i = end - 1
+		case c == '[':
+			end := strings.IndexByte(selector[i:], ']')
+			if end == -1 {
+				end = len(selector) - i - 1
+			}
+			out.WriteString(selector[i : i+end+1])
+			i += end
+		case strings.HasPrefix(selector[i:], ":global("):
+			close := cssMatchingParen(selector, i+len(":global"))
+			out.WriteString(selector[i+len(":global(") : close])
+			i = close
+		case c == '.':
+			name := cssIdent.FindString(selector[i+1:])
+			if name == "" {
+				out.WriteByte(c)
+				continue
+			}
+			if _, ok := classes[name]; !ok {
+				classes[name] = scope(name)
+			}
+			out.WriteString("." + classes[name])
+			i += len(name)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// index of the first character of chars outside of strings and comments
+func cssIndexAny(css, chars string) int {
+	for i := 0; i < len(css); i++ {
+		switch c := css[i]; {
+		case c == '"' || c == '\'':
+			i = cssStringEnd(css, i) - 1
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end == -1 {
+				return -1
+			}
+			i += end + 3
+		case strings.IndexByte(chars, c) != -1:
+			return i
+		}
+	}
+	return -1
+}
+
+func cssMatchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); {
+		j := cssIndexAny(css[i:], "{}")
+		if j == -1 {
+			break
+		}
+		i += j
+		if css[i] == '{' {
+			depth++
+		} else if depth--; depth == 0 {
+			return i
+		}
+		i++
+	}
+	return len(css) - 1
+}
+
+func cssMatchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s) - 1
+}
+
+// index after the closing quote of the string starting at start
+func cssStringEnd(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// writes "<file>.d.ts" next to the stylesheet so imports of it are typed
+func writeCssModuleDts(file string, classes map[string]string) {
+	names := make([]string, 0, len(classes))
+	for name := range classes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var dts strings.Builder
+	dts.WriteString("// generated by ags from " + filepath.Base(file) + "\n")
+	dts.WriteString("declare const classes: {\n")
+	for _, name := range names {
+		dts.WriteString(fmt.Sprintf("  readonly %s: string\n", jsString(name)))
+	}
+	dts.WriteString("}\n")
+	dts.WriteString("export const css: string\n")
+	dts.WriteString("export default classes\n")
+
+	writeSourceDts(file+".d.ts", dts.String())
+}
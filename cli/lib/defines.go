@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var jsIdentifier = regexp.MustCompile(`^[A-Za-z_$][\w$]*$`)
+
+// tsType is the widened type of a JSON value, e.g "boolean" instead of "true"
+func tsType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		var types []string
+		for _, item := range v {
+			if t := tsType(item); !slices.Contains(types, t) {
+				types = append(types, t)
+			}
+		}
+		switch len(types) {
+		case 0:
+			return "unknown[]"
+		case 1:
+			return types[0] + "[]"
+		}
+		return "Array<" + strings.Join(types, " | ") + ">"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		fields := make([]string, len(keys))
+		for i, key := range keys {
+			name := key
+			if !jsIdentifier.MatchString(key) {
+				name = jsString(key)
+			}
+			fields[i] = name + ": " + tsType(v[key])
+		}
+		return "{ " + strings.Join(fields, "; ") + " }"
+	}
+	return "unknown"
+}
+
+// DefineType infers the type of the value of a define,
+// values which are not JSON are identifiers or member expressions
+func DefineType(value string) string {
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err == nil {
+		return tsType(v)
+	}
+	if value == "undefined" {
+		return "undefined"
+	}
+	return "unknown"
+}
+
+// DefinesDts declares the global identifiers replaced by defines and the
+// variables of import.meta.env, SRC is declared by env.d.ts and other
+// member expressions can not be declared
+func DefinesDts(defines map[string]string) string {
+	var keys, env []string
+	for key := range defines {
+		if name, ok := strings.CutPrefix(key, "import.meta.env."); ok && jsIdentifier.MatchString(name) {
+			env = append(env, name)
+		} else if key != "SRC" && jsIdentifier.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	slices.Sort(env)
+
+	var b strings.Builder
+	b.WriteString("// generated by ags from the --define and --env-file flags, do not edit\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "declare const %s: %s\n", key, DefineType(defines[key]))
+	}
+
+	if len(env) > 0 {
+		b.WriteString("\ninterface ImportMetaEnv {\n")
+		for _, name := range env {
+			fmt.Fprintf(&b, "  readonly %s: %s\n", name, DefineType(defines["import.meta.env."+name]))
+		}
+		b.WriteString("}\n\ninterface ImportMeta {\n  readonly env: ImportMetaEnv\n}\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DotenvError is a line of an env file which can not be parsed
+type DotenvError struct {
+	Line int
+	Msg  string
+}
+
+func (e *DotenvError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Line, e.Msg)
+}
+
+// ParseDotenv parses the KEY=VALUE lines of an env file, lines can start with "export",
+// single quoted values are literal, double quoted ones can contain escapes and span lines,
+// "#" starts a comment unless it is quoted or part of an unquoted value
+func ParseDotenv(content string) (map[string]string, error) {
+	vars := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		start := i + 1
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !jsIdentifier.MatchString(key) || strings.Contains(key, "$") {
+			return nil, &DotenvError{start, "expected KEY=VALUE"}
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end == -1 {
+				return nil, &DotenvError{start, "unterminated single quote"}
+			}
+			value = value[1 : end+1]
+
+		case strings.HasPrefix(value, `"`):
+			var b strings.Builder
+			rest, closed := value[1:], false
+			for !closed {
+				for j := 0; j < len(rest); j++ {
+					c := rest[j]
+					if c == '"' {
+						closed = true
+						break
+					}
+					if c != '\\' || j+1 == len(rest) {
+						b.WriteByte(c)
+						continue
+					}
+					j++
+					switch rest[j] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					case 'r':
+						b.WriteByte('\r')
+					default:
+						b.WriteByte(rest[j])
+					}
+				}
+				if !closed {
+					if i+1 == len(lines) {
+						return nil, &DotenvError{start, "unterminated double quote"}
+					}
+					i++
+					b.WriteByte('\n')
+					rest = lines[i]
+				}
+			}
+			value = b.String()
+
+		default:
+			if comment := strings.Index(value, " #"); comment != -1 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+
+		vars[key] = value
+	}
+
+	return vars, nil
+}
+
+// EnvDefines are the defines which replace import.meta.env.KEY and process.env.KEY
+// with the values of vars, reading other keys of either object results in undefined
+func EnvDefines(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	object, err := json.Marshal(vars)
+	if err != nil {
+		Err(err)
+	}
+
+	defines := []string{"import.meta.env=" + string(object), "process.env=" + string(object)}
+	for _, key := range keys {
+		value := jsString(vars[key])
+		defines = append(defines, "import.meta.env."+key+"="+value, "process.env."+key+"="+value)
+	}
+	return defines
+}
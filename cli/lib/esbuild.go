@@ -2,18 +2,58 @@
 package lib
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/evanw/esbuild/pkg/api"
+	"github.com/titanous/json5"
 )
 
-var agsJsPackage string
+var (
+	agsJsPackage string
+	bash         string
+)
 
-func Initialize(jsPackage string) {
+func Initialize(jsPackage, bashPath string) {
 	agsJsPackage = jsPackage
+	bash = bashPath
+}
+
+// marks a resolve request made by resolvePath so the plugins don't handle it again
+type resolving struct{}
+
+// resolvePath resolves specifier with esbuild's resolver, which supports packages,
+// aliases, absolute paths and tsconfig paths. Specifiers which esbuild can not resolve
+// fall back to a path relative to the importer, e.g "style.scss" without "./"
+func resolvePath(build api.PluginBuild, args api.OnResolveArgs, specifier string) (api.OnResolveResult, bool) {
+	result := build.Resolve(specifier, api.ResolveOptions{
+		Importer:   args.Importer,
+		ResolveDir: args.ResolveDir,
+		Kind:       args.Kind,
+		PluginData: resolving{},
+	})
+
+	if len(result.Errors) == 0 {
+		return api.OnResolveResult{Path: result.Path}, true
+	}
+
+	if relative := path.Join(args.ResolveDir, specifier); !path.IsAbs(specifier) && FileExists(relative) {
+		return api.OnResolveResult{Path: relative}, true
+	}
+
+	return api.OnResolveResult{Errors: result.Errors}, false
+}
+
+// whether the resolve request was made by resolvePath
+func isResolving(args api.OnResolveArgs) bool {
+	_, ok := args.PluginData.(resolving)
+	return ok
 }
 
 var inlinePlugin api.Plugin = api.Plugin{
@@ -21,15 +61,11 @@ var inlinePlugin api.Plugin = api.Plugin{
 	Setup: func(build api.PluginBuild) {
 		build.OnResolve(api.OnResolveOptions{Filter: "^inline:"},
 			func(args api.OnResolveArgs) (api.OnResolveResult, error) {
-				resolved := path.Join(
-					args.ResolveDir,
-					strings.Replace(args.Path, "inline:", "", 1),
-				)
-
-				return api.OnResolveResult{
-					Path:      resolved,
-					Namespace: "inline",
-				}, nil
+				result, ok := resolvePath(build, args, strings.Replace(args.Path, "inline:", "", 1))
+				if ok {
+					result.Namespace = "inline"
+				}
+				return result, nil
 			},
 		)
 
@@ -51,80 +87,150 @@ var inlinePlugin api.Plugin = api.Plugin{
 	},
 }
 
-var sassPlugin api.Plugin = api.Plugin{
-	Name: "sass",
-	Setup: func(build api.PluginBuild) {
-		build.OnResolve(api.OnResolveOptions{Filter: `.*\.scss$`},
-			func(args api.OnResolveArgs) (api.OnResolveResult, error) {
-				return api.OnResolveResult{
-					Path: path.Join(
-						args.ResolveDir,
-						args.Path,
-					),
-					Namespace: "sass",
-				}, nil
-			},
-		)
+func sassPlugin(check gtkCss) api.Plugin {
+	return api.Plugin{
+		Name: "sass",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: `.*\.scss$`},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					if isResolving(args) {
+						return api.OnResolveResult{}, nil
+					}
 
-		build.OnLoad(api.OnLoadOptions{Filter: `.*\.scss$`, Namespace: "sass"},
-			func(args api.OnLoadArgs) (api.OnLoadResult, error) {
-				sass := Exec("sass", args.Path, "-I", filepath.Dir(args.Path))
-				// if cwd is the path of the currently loaded file sass warns about it
-				// in order to avoid the deprecation warning we explicitly set it to something else
-				sass.Dir = "/"
-				sass.Stderr = os.Stderr
+					result, ok := resolvePath(build, args, args.Path)
+					if ok {
+						result.Namespace = "sass"
+					}
+					return result, nil
+				},
+			)
 
-				data, err := sass.Output()
-				if err != nil {
-					return api.OnLoadResult{}, err
-				}
+			build.OnLoad(api.OnLoadOptions{Filter: `.*\.scss$`, Namespace: "sass"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					result, err := compileSass(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
 
-				content := strings.TrimSpace(string(data))
-				return api.OnLoadResult{
-					Contents:   &content,
-					WatchFiles: []string{args.Path},
-					Loader:     api.LoaderText,
-				}, nil
-			})
-	},
+					warnings, errors := check.messages(result.Css, args.Path, result.SourceMap)
+					return api.OnLoadResult{
+						Contents:   &result.Css,
+						WatchFiles: result.Files,
+						Loader:     api.LoaderText,
+						Warnings:   warnings,
+						Errors:     errors,
+					}, nil
+				})
+		},
+	}
 }
 
-var blpPlugin api.Plugin = api.Plugin{
-	Name: "blueprint",
+// dataPlugin parses configuration files at build time into JSON modules
+var dataPlugin api.Plugin = api.Plugin{
+	Name: "data",
 	Setup: func(build api.PluginBuild) {
-		build.OnResolve(api.OnResolveOptions{Filter: `.*\.blp$`},
-			func(args api.OnResolveArgs) (api.OnResolveResult, error) {
-				return api.OnResolveResult{
-					Path: path.Join(
-						args.ResolveDir,
-						args.Path,
-					),
-					Namespace: "blueprint",
-				}, nil
-			},
-		)
-
-		build.OnLoad(api.OnLoadOptions{Filter: `.*\.blp$`, Namespace: "blueprint"},
+		build.OnLoad(api.OnLoadOptions{Filter: `\.(ya?ml|toml|json5)$`},
 			func(args api.OnLoadArgs) (api.OnLoadResult, error) {
-				blp := Exec("blueprint-compiler", "compile", args.Path)
-				blp.Stderr = os.Stderr
+				data, err := os.ReadFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+
+				var value any
+				switch filepath.Ext(args.Path) {
+				case ".json5":
+					err = json5.Unmarshal(data, &value)
+				case ".toml":
+					value, err = parseToml(string(data))
+				default:
+					value, err = parseYaml(string(data))
+				}
+
+				if err != nil {
+					msg := api.Message{Text: err.Error()}
+					if e, ok := err.(*dataError); ok {
+						msg.Text = e.Msg
+						lines := strings.Split(string(data), "\n")
+						msg.Location = &api.Location{File: args.Path, Line: e.Line}
+						if e.Line <= len(lines) {
+							msg.Location.LineText = strings.TrimRight(lines[e.Line-1], "\r")
+						}
+					}
+					return api.OnLoadResult{Errors: []api.Message{msg}}, nil
+				}
 
-				data, err := blp.Output()
+				out, err := json.Marshal(value)
 				if err != nil {
 					return api.OnLoadResult{}, err
 				}
 
-				content := strings.TrimSpace(string(data))
+				content := string(out)
 				return api.OnLoadResult{
 					Contents:   &content,
 					WatchFiles: []string{args.Path},
-					Loader:     api.LoaderText,
+					Loader:     api.LoaderJSON,
 				}, nil
 			})
 	},
 }
 
-func sliceToKV(keyValuePairs []string) map[string]string {
+// typelibPlugin reports "gi://" imports which gjs won't be able to load at runtime
+func typelibPlugin() api.Plugin {
+	var typelibs map[string]GirFile
+	var once sync.Once
+
+	return api.Plugin{
+		Name: "typelib",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: "^gi://"},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					once.Do(func() { typelibs = FindTypelibs() })
+					result := api.OnResolveResult{Path: args.Path, External: true}
+
+					// dynamic imports are usually guarded for optional dependencies
+					gi, ok := ParseGiImport(args.Path)
+					if !ok || args.Kind == api.ResolveJSDynamicImport {
+						return result, nil
+					}
+
+					if _, ok := FindTypelib(typelibs, gi.Namespace, gi.Version); ok {
+						return result, nil
+					}
+
+					msg := api.Message{
+						Text: `namespace "` + gi.Namespace + `" not found in the typelib search path`,
+						Notes: []api.Note{{
+							Text: "searched " + strings.Join(TypelibDirPatterns(), ", "),
+						}},
+					}
+
+					if versions := TypelibVersions(typelibs, gi.Namespace); len(versions) > 0 {
+						msg.Text = `version "` + gi.Version + `" of "` + gi.Namespace + `" not found`
+						msg.Notes = []api.Note{{
+							Text: "available versions: " + strings.Join(versions, ", "),
+						}}
+					}
+
+					result.Errors = []api.Message{msg}
+					return result, nil
+				},
+			)
+		},
+	}
+}
+
+// sets the default gettext domain before any module of the bundle is evaluated,
+// translations are looked up in the directory the launcher extracted them to
+func textDomainBanner(domain string) string {
+	return strings.Join([]string{
+		`import __agsGLib from "gi://GLib?version=2.0";`,
+		`import __agsGettext from "gettext";`,
+		`if (__agsGLib.getenv("AGS_LOCALE_DIR")) __agsGettext.bindtextdomain(` + jsString(domain) + `, __agsGLib.getenv("AGS_LOCALE_DIR"));`,
+		`__agsGettext.textdomain(` + jsString(domain) + `);`,
+	}, "\n")
+}
+
+func SliceToKV(keyValuePairs []string) map[string]string {
 	pairs := make(map[string]string)
 	for _, pair := range keyValuePairs {
 		parts := strings.SplitN(pair, "=", 2)
@@ -137,13 +243,68 @@ func sliceToKV(keyValuePairs []string) map[string]string {
 	return pairs
 }
 
+// Entry is one of the programs of a build with multiple entry points
+type Entry struct {
+	Name string // name of the output file without extension
+	Path string
+}
+
 type BundleOpts struct {
 	Infile           string
 	Outfile          string
+	Entries          []Entry // used instead of Infile and Outfile to build into Outdir
+	Outdir           string
 	Defines          []string
 	Alias            []string
 	GtkVersion       uint
 	WorkingDirectory string
+	Metafile         bool
+	SkipTypelibCheck bool
+	Minify           bool
+	Sourcemap        string // inline, external or none, defaults to inline
+	LegalComments    string // none, inline, eof or external, defaults to eof
+	Drop             []string
+	StrictCss        bool   // report css which gtk does not support as errors
+	TextDomain       string // gettext domain bound to $AGS_LOCALE_DIR at startup
+	OutputPath       string // where the caller writes the in-memory output, external files are named after it
+
+	jsxPreserve bool
+	resolveOnly bool // only follow the imports of scripts, no loader runs
+}
+
+var sourcemaps = map[string]api.SourceMap{
+	"":         api.SourceMapInline,
+	"inline":   api.SourceMapInline,
+	"external": api.SourceMapLinked,
+	"none":     api.SourceMapNone,
+}
+
+var legalComments = map[string]api.LegalComments{
+	"":         api.LegalCommentsEndOfFile,
+	"none":     api.LegalCommentsNone,
+	"inline":   api.LegalCommentsInline,
+	"eof":      api.LegalCommentsEndOfFile,
+	"external": api.LegalCommentsExternal,
+}
+
+var drops = map[string]api.Drop{
+	"console":  api.DropConsole,
+	"debugger": api.DropDebugger,
+}
+
+func optionOf[T any](options map[string]T, flag, value string) T {
+	option, ok := options[value]
+	if !ok {
+		valid := make([]string, 0, len(options))
+		for key := range options {
+			if key != "" {
+				valid = append(valid, key)
+			}
+		}
+		slices.Sort(valid)
+		Err(fmt.Sprintf(`invalid %s "%s", valid values are: %s`, flag, value, strings.Join(valid, ", ")))
+	}
+	return option
 }
 
 // TODO: bundle plugins
@@ -152,11 +313,20 @@ type BundleOpts struct {
 // http plugin with caching
 
 func Bundle(opts BundleOpts) api.BuildResult {
-	defines := sliceToKV(opts.Defines)
-	alias := sliceToKV(opts.Alias)
+	defines := SliceToKV(opts.Defines)
+	alias := SliceToKV(opts.Alias)
 
 	if _, ok := defines["SRC"]; !ok {
-		defines["SRC"] = `"` + filepath.Dir(opts.Infile) + `"`
+		src := filepath.Dir(opts.Infile)
+		// programs share their modules, so SRC can only be the root
+		if len(opts.Entries) > 0 {
+			src = opts.WorkingDirectory
+			if src == "" {
+				src = Cwd()
+			}
+			src, _ = filepath.Abs(src)
+		}
+		defines["SRC"] = `"` + src + `"`
 	}
 
 	if _, ok := alias["gnim"]; !ok {
@@ -167,24 +337,44 @@ func Bundle(opts BundleOpts) api.BuildResult {
 		alias["ags"] = agsJsPackage + "/lib"
 	}
 
+	// the version inference bundles before the version is known
+	check := gtkCss{Version: opts.GtkVersion, Strict: opts.StrictCss}
+	if opts.jsxPreserve {
+		check.Version = 0
+	}
+
 	buildOpts := api.BuildOptions{
-		Color:       api.ColorAlways,
-		LogLevel:    api.LogLevelWarning,
-		EntryPoints: []string{opts.Infile},
-		Bundle:      true,
-		Outfile:     opts.Outfile,
-		Format:      api.FormatESModule,
-		Platform:    api.PlatformNeutral,
-		Define:      defines,
-		Alias:       alias,
-		Target:      api.ES2022,
-		Sourcemap:   api.SourceMapInline,
+		Color:             api.ColorAlways,
+		LogLevel:          api.LogLevelWarning,
+		EntryPoints:       []string{opts.Infile},
+		Bundle:            true,
+		Outfile:           opts.Outfile,
+		Format:            api.FormatESModule,
+		Platform:          api.PlatformNeutral,
+		Define:            defines,
+		Alias:             alias,
+		Target:            api.ES2022,
+		Sourcemap:         optionOf(sourcemaps, "sourcemap", opts.Sourcemap),
+		LegalComments:     optionOf(legalComments, "legal comments", opts.LegalComments),
+		MinifyWhitespace:  opts.Minify,
+		MinifyIdentifiers: opts.Minify,
+		MinifySyntax:      opts.Minify,
+		// GObject.registerClass derives GType and CSS names from class names
+		KeepNames: opts.Minify,
 		Engines: []api.Engine{
 			{Name: api.EngineFirefox, Version: "115"},
 		},
 		Loader: map[string]api.Loader{
-			".js":  api.LoaderJSX,
-			".css": api.LoaderText,
+			".js":   api.LoaderJSX,
+			".css":  api.LoaderText,
+			".png":  api.LoaderBinary,
+			".jpg":  api.LoaderBinary,
+			".jpeg": api.LoaderBinary,
+			".gif":  api.LoaderBinary,
+			".webp": api.LoaderBinary,
+			".wav":  api.LoaderBinary,
+			".ogg":  api.LoaderBinary,
+			".mp3":  api.LoaderBinary,
 		},
 		External: []string{
 			"console",
@@ -197,8 +387,12 @@ func Bundle(opts BundleOpts) api.BuildResult {
 		},
 		Plugins: []api.Plugin{
 			inlinePlugin,
-			sassPlugin,
-			blpPlugin,
+			cssModulePlugin(check),
+			sassPlugin(check),
+			cssPlugin(check),
+			blpPlugin(),
+			fontPlugin,
+			dataPlugin,
 		},
 	}
 
@@ -206,23 +400,91 @@ func Bundle(opts BundleOpts) api.BuildResult {
 		buildOpts.Write = true
 	}
 
+	// shared modules are split into chunks which are imported by the entries
+	if len(opts.Entries) > 0 {
+		buildOpts.EntryPoints = nil
+		for _, entry := range opts.Entries {
+			buildOpts.EntryPointsAdvanced = append(buildOpts.EntryPointsAdvanced, api.EntryPoint{
+				InputPath:  entry.Path,
+				OutputPath: entry.Name,
+			})
+		}
+		buildOpts.Outfile = ""
+		buildOpts.Outdir = opts.Outdir
+		buildOpts.Splitting = true
+		buildOpts.ChunkNames = "chunks/[name]-[hash]"
+		buildOpts.Write = opts.Outdir != ""
+	}
+
+	if opts.Metafile {
+		buildOpts.Metafile = true
+	}
+
+	for _, d := range opts.Drop {
+		buildOpts.Drop |= optionOf(drops, "drop", d)
+	}
+
+	if opts.jsxPreserve {
+		buildOpts.JSX = api.JSXPreserve
+		// warnings are reported by the actual build
+		buildOpts.LogLevel = api.LogLevelError
+	}
+
+	if opts.TextDomain != "" && !opts.jsxPreserve {
+		buildOpts.Banner = map[string]string{"js": textDomainBanner(opts.TextDomain)}
+	}
+
+	if !opts.SkipTypelibCheck {
+		buildOpts.Plugins = append(buildOpts.Plugins, typelibPlugin())
+	}
+
+	root := Cwd()
 	if opts.WorkingDirectory != "" {
 		dir, err := filepath.Abs(opts.WorkingDirectory)
 		if err != nil {
 			Err(err)
 		}
 
+		root = dir
 		buildOpts.AbsWorkingDir = dir
-		buildOpts.TsconfigRaw = GetTsconfig(dir, opts.GtkVersion)
-	} else {
-		buildOpts.TsconfigRaw = GetTsconfig(Cwd(), opts.GtkVersion)
+	}
+
+	buildOpts.TsconfigRaw = GetTsconfig(root, opts.GtkVersion)
+
+	loaders := LoadProjectConfig(root).Loaders
+	if len(loaders) > 0 {
+		// before the builtin plugins so they take precedence over the data loaders
+		buildOpts.Plugins = append([]api.Plugin{execLoaderPlugin(loaders, root)}, buildOpts.Plugins...)
+	}
+
+	if opts.resolveOnly {
+		buildOpts.Plugins = []api.Plugin{resolveOnlyPlugin(loaders)}
+	}
+
+	// external files are named after the outfile, which is not written
+	// when the output is only returned in memory
+	external := buildOpts.Sourcemap == api.SourceMapLinked ||
+		buildOpts.LegalComments == api.LegalCommentsExternal
+	if opts.Outfile == "" && opts.Outdir == "" && external {
+		// paths in the source map are relative to the outfile
+		buildOpts.Outfile = opts.OutputPath
+		if buildOpts.Outfile == "" {
+			dir := buildOpts.AbsWorkingDir
+			if dir == "" {
+				dir = Cwd()
+			}
+			buildOpts.Outfile = filepath.Join(dir, "ags.js")
+		}
+		if buildOpts.Sourcemap == api.SourceMapLinked {
+			buildOpts.Sourcemap = api.SourceMapExternal
+		}
 	}
 
 	result := api.Build(buildOpts)
 
 	// TODO: custom error logs
 	if len(result.Errors) > 0 {
-		os.Exit(1)
+		Exit(1)
 	}
 
 	return result
@@ -0,0 +1,156 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// ExecLoader loads files by running a command and using its stdout as the module
+//
+//	{
+//	  "extension": ".pug",
+//	  "command": "pug --client --name render < {file}",
+//	  "loader": "js",
+//	  "watch": ["mixins/*.pug"]
+//	}
+type ExecLoader struct {
+	Extension string   `json:"extension"` // file extension including the dot
+	Filter    string   `json:"filter"`    // regex matched against the path, instead of extension
+	Command   string   `json:"command"`   // shell command with {file}, {dir} and {root} placeholders
+	Cwd       string   `json:"cwd"`       // working directory of the command, defaults to {dir}
+	Loader    string   `json:"loader"`    // text, js, jsx, ts, tsx, json, binary or base64
+	Watch     []string `json:"watch"`     // globs relative to the root which trigger a rebuild
+}
+
+var execLoaders = map[string]api.Loader{
+	"":       api.LoaderText,
+	"text":   api.LoaderText,
+	"js":     api.LoaderJS,
+	"jsx":    api.LoaderJSX,
+	"ts":     api.LoaderTS,
+	"tsx":    api.LoaderTSX,
+	"json":   api.LoaderJSON,
+	"binary": api.LoaderBinary,
+	"base64": api.LoaderBase64,
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (l ExecLoader) filter() string {
+	if l.Filter != "" {
+		return l.Filter
+	}
+	return regexp.QuoteMeta(l.Extension) + "$"
+}
+
+// replaces the placeholders of a template, quote is applied to the values
+func (l ExecLoader) expand(template, file, root string, quote func(string) string) string {
+	return strings.NewReplacer(
+		"{file}", quote(file),
+		"{dir}", quote(filepath.Dir(file)),
+		"{root}", quote(root),
+	).Replace(template)
+}
+
+// files of root matching any of the globs, a glob without
+// a slash matches the name of files in any directory
+func globFiles(root string, globs []string) []string {
+	var files []string
+	filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && (d.Name() == "node_modules" || d.Name() == ".git") {
+			return filepath.SkipDir
+		}
+
+		rel, _ := filepath.Rel(root, file)
+		rel = filepath.ToSlash(rel)
+		for _, glob := range globs {
+			name := rel
+			if !strings.Contains(glob, "/") {
+				name = path.Base(rel)
+			}
+			if ok, _ := path.Match(glob, name); ok && !d.IsDir() {
+				files = append(files, file)
+				break
+			}
+		}
+		return nil
+	})
+	return files
+}
+
+func execLoaderPlugin(loaders []ExecLoader, root string) api.Plugin {
+	return api.Plugin{
+		Name: "exec",
+		Setup: func(build api.PluginBuild) {
+			for i, l := range loaders {
+				if l.Command == "" || l.Extension == "" && l.Filter == "" {
+					Err(fmt.Sprintf("%s: loader %d needs a command and an extension or filter", ProjectConfigFile, i))
+				}
+
+				loader, ok := execLoaders[l.Loader]
+				if !ok {
+					Err(fmt.Sprintf(`%s: unknown loader "%s"`, ProjectConfigFile, l.Loader))
+				}
+
+				var watch []string
+				var once sync.Once
+
+				build.OnLoad(api.OnLoadOptions{Filter: l.filter()},
+					func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+						once.Do(func() { watch = globFiles(root, l.Watch) })
+
+						cwd := "{dir}"
+						if l.Cwd != "" {
+							cwd = l.Cwd
+						}
+						dir := l.expand(cwd, args.Path, root, func(s string) string { return s })
+						if !filepath.IsAbs(dir) {
+							dir = filepath.Join(root, dir)
+						}
+
+						cmd := Exec(bash, "-c", l.expand(l.Command, args.Path, root, shellQuote))
+						cmd.Dir = dir
+
+						var stdout, stderr bytes.Buffer
+						cmd.Stdout = &stdout
+						cmd.Stderr = &stderr
+
+						if err := cmd.Run(); err != nil {
+							return api.OnLoadResult{
+								Errors: []api.Message{{
+									Text:  fmt.Sprintf("%s: %v", l.Command, err),
+									Notes: []api.Note{{Text: strings.TrimSpace(stderr.String())}},
+								}},
+							}, nil
+						}
+
+						content := stdout.String()
+						result := api.OnLoadResult{
+							Contents:   &content,
+							WatchFiles: append([]string{args.Path}, watch...),
+							Loader:     loader,
+						}
+
+						if msg := strings.TrimSpace(stderr.String()); msg != "" {
+							result.Warnings = []api.Message{{Text: msg}}
+						}
+
+						return result, nil
+					})
+			}
+		},
+	}
+}
@@ -0,0 +1,226 @@
+package lib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// extensions of the font files which can be imported and shipped
+var FontExtensions = []string{".ttf", ".otf", ".ttc", ".woff", ".woff2"}
+
+func isFont(file string) bool {
+	return slices.Contains(FontExtensions, strings.ToLower(filepath.Ext(file)))
+}
+
+// FindFonts returns the font files in dir
+func FindFonts(dir string) []string {
+	globs := make([]string, len(FontExtensions))
+	for i, ext := range FontExtensions {
+		globs[i] = "*" + ext
+	}
+	return globFiles(dir, globs)
+}
+
+// Fonts returns the font files imported by the bundle, relative to its working directory
+func (m Metafile) Fonts() []string {
+	var fonts []string
+	for input := range m.Inputs {
+		if isFont(input) && !strings.Contains(input, ":") {
+			fonts = append(fonts, input)
+		}
+	}
+	slices.Sort(fonts)
+	return fonts
+}
+
+var errWoff2 = errors.New("woff2 fonts are not parsed")
+
+// sfnt tables of a font, woff tables are decompressed
+func fontTables(data []byte) (map[string][]byte, error) {
+	be := binary.BigEndian
+	tables := map[string][]byte{}
+	start := 0 // of the table directory
+	if len(data) < 12 {
+		return nil, fmt.Errorf("not a font")
+	}
+
+	switch string(data[:4]) {
+	case "wOFF":
+		if len(data) < 44 {
+			return nil, fmt.Errorf("truncated woff header")
+		}
+		n := int(be.Uint16(data[12:]))
+		for i := 0; i < n; i++ {
+			entry := 44 + i*20
+			if entry+20 > len(data) {
+				return nil, fmt.Errorf("truncated woff table directory")
+			}
+			tag := string(data[entry : entry+4])
+			offset, compressed, size := be.Uint32(data[entry+4:]), be.Uint32(data[entry+8:]), be.Uint32(data[entry+12:])
+			if uint64(offset)+uint64(compressed) > uint64(len(data)) {
+				return nil, fmt.Errorf("truncated woff table %s", tag)
+			}
+			table := data[offset : offset+compressed]
+			if compressed < size {
+				r, err := zlib.NewReader(bytes.NewReader(table))
+				if err != nil {
+					return nil, err
+				}
+				if table, err = io.ReadAll(r); err != nil {
+					return nil, err
+				}
+			}
+			tables[tag] = table
+		}
+		return tables, nil
+
+	case "wOF2":
+		// tables are compressed with brotli as a whole
+		return nil, errWoff2
+
+	case "ttcf":
+		// the first font of the collection, its table offsets are still
+		// relative to the start of the collection
+		if len(data) < 16 {
+			return nil, fmt.Errorf("truncated font collection")
+		}
+		offset := be.Uint32(data[12:])
+		if uint64(offset)+12 > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated font collection")
+		}
+		start = int(offset)
+		fallthrough
+
+	default:
+		n := int(be.Uint16(data[start+4:]))
+		for i := 0; i < n; i++ {
+			record := start + 12 + i*16
+			if record+16 > len(data) {
+				return nil, fmt.Errorf("truncated table directory")
+			}
+			tag := string(data[record : record+4])
+			offset, size := be.Uint32(data[record+8:]), be.Uint32(data[record+12:])
+			if uint64(offset)+uint64(size) > uint64(len(data)) {
+				return nil, fmt.Errorf("truncated table %s", tag)
+			}
+			tables[tag] = data[offset : offset+size]
+		}
+		return tables, nil
+	}
+}
+
+// FontFamily reads the family name of a font from its name table,
+// the typographic family is preferred over the legacy one
+// https://learn.microsoft.com/en-us/typography/opentype/spec/name
+func FontFamily(data []byte) (string, error) {
+	be := binary.BigEndian
+	tables, err := fontTables(data)
+	if err != nil {
+		return "", err
+	}
+
+	name, ok := tables["name"]
+	if !ok || len(name) < 6 {
+		return "", fmt.Errorf("font has no name table")
+	}
+
+	count, storage := int(be.Uint16(name[2:])), int(be.Uint16(name[4:]))
+	names := map[uint16]string{}
+	for i := 0; i < count; i++ {
+		record := 6 + i*12
+		if record+12 > len(name) {
+			break
+		}
+
+		platform, language, id := be.Uint16(name[record:]), be.Uint16(name[record+4:]), be.Uint16(name[record+6:])
+		length, offset := int(be.Uint16(name[record+8:])), int(be.Uint16(name[record+10:]))
+		if storage+offset+length > len(name) || id != 1 && id != 16 {
+			continue
+		}
+		value := name[storage+offset : storage+offset+length]
+
+		switch {
+		case platform == 3 || platform == 0:
+			// utf-16 of windows and unicode names, english is preferred
+			if _, ok := names[id]; ok && language != 0x409 {
+				continue
+			}
+			units := make([]uint16, len(value)/2)
+			for j := range units {
+				units[j] = be.Uint16(value[j*2:])
+			}
+			names[id] = string(utf16.Decode(units))
+		case platform == 1 && language == 0:
+			if _, ok := names[id]; !ok {
+				names[id] = string(value)
+			}
+		}
+	}
+
+	for _, id := range []uint16{16, 1} {
+		if family := strings.TrimSpace(names[id]); family != "" {
+			return family, nil
+		}
+	}
+	return "", fmt.Errorf("font has no family name")
+}
+
+// fontPlugin turns font imports into the name of their family,
+// the files themselves are shipped next to the bundle
+var fontPlugin api.Plugin = api.Plugin{
+	Name: "font",
+	Setup: func(build api.PluginBuild) {
+		build.OnLoad(api.OnLoadOptions{Filter: `(?i)\.(ttf|otf|ttc|woff2?)$`},
+			func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				data, err := os.ReadFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+
+				var warnings []api.Message
+				family, err := FontFamily(data)
+				if errors.Is(err, errWoff2) {
+					family = strings.TrimSuffix(filepath.Base(args.Path), filepath.Ext(args.Path))
+				} else if err != nil {
+					family = strings.TrimSuffix(filepath.Base(args.Path), filepath.Ext(args.Path))
+					warnings = append(warnings, api.Message{
+						Text:  fmt.Sprintf(`could not read the family name of %s: %v`, filepath.Base(args.Path), err),
+						Notes: []api.Note{{Text: `the name of the file "` + family + `" is used instead`}},
+					})
+				}
+
+				content := "export default " + jsString(family)
+				return api.OnLoadResult{
+					Contents:   &content,
+					WatchFiles: []string{args.Path},
+					Loader:     api.LoaderJS,
+					Warnings:   warnings,
+				}, nil
+			})
+	},
+}
+
+// Fontconfig is a configuration which adds dir to the fonts of the included configuration
+// https://www.freedesktop.org/software/fontconfig/fontconfig-user.html
+func Fontconfig(dir, include string) string {
+	return strings.Join([]string{
+		`<?xml version="1.0"?>`,
+		`<!DOCTYPE fontconfig SYSTEM "urn:fontconfig:fonts.dtd">`,
+		`<fontconfig>`,
+		`  <include ignore_missing="yes">` + html.EscapeString(include) + `</include>`,
+		`  <dir>` + html.EscapeString(dir) + `</dir>`,
+		`</fontconfig>`,
+	}, "\n")
+}
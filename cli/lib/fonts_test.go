@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// an sfnt font with a name table, placed at base in a file
+func testFont(base int, family string) []byte {
+	be := binary.BigEndian
+
+	name := be.AppendUint16(nil, 0)
+	name = be.AppendUint16(name, 1)
+	name = be.AppendUint16(name, 18)
+	for _, v := range []int{1, 0, 0, 1, len(family), 0} {
+		name = be.AppendUint16(name, uint16(v))
+	}
+	name = append(name, family...)
+
+	font := []byte{0, 1, 0, 0}
+	font = be.AppendUint16(font, 1)
+	font = append(font, make([]byte, 6)...)
+	font = append(font, "name"...)
+	font = be.AppendUint32(font, 0)
+	font = be.AppendUint32(font, uint32(base+28))
+	font = be.AppendUint32(font, uint32(len(name)))
+	return append(font, name...)
+}
+
+func TestFontFamily(t *testing.T) {
+	collection := append([]byte("ttcf\x00\x01\x00\x00\x00\x00\x00\x01\x00\x00\x00\x10"), testFont(16, "Test Sans")...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+		err  string
+	}{
+		{name: "font", data: testFont(0, "Test Sans"), want: "Test Sans"},
+		{name: "collection", data: collection, want: "Test Sans"},
+		{name: "too short", data: []byte("\x00\x01\x00\x00"), err: "not a font"},
+		{name: "truncated collection header", data: []byte("ttcf\x00\x01\x00\x00\x00\x00\x00\x01\x00"), err: "truncated font collection"},
+		{name: "collection offset out of range", data: []byte("ttcf\x00\x01\x00\x00\x00\x00\x00\x01\xff\xff\xff\xf8"), err: "truncated font collection"},
+		{name: "truncated table directory", data: testFont(0, "Test Sans")[:20], err: "truncated table directory"},
+		{name: "truncated table", data: testFont(0, "Test Sans")[:40], err: "truncated table name"},
+		{name: "woff2", data: []byte("wOF2\x00\x01\x00\x00\x00\x00\x00\x00"), err: "woff2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := FontFamily(test.data)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
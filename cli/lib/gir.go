@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GirDirPatterns are the directories searched for .gir files, they can contain globs
+func GirDirPatterns() []string {
+	dirs := []string{
+		"/usr/local/share/gir-1.0",
+		"/usr/share/gir-1.0",
+		"/usr/share/*/gir-1.0",
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("EXTRA_GIR_DIRS")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// GirFile is a namespace found as a .gir or .typelib file
+type GirFile struct {
+	Namespace string
+	Version   string
+	Path      string
+}
+
+// Name is the identifier of the namespace e.g "Gtk-4.0"
+func (g GirFile) Name() string {
+	return g.Namespace + "-" + g.Version
+}
+
+// files with ext in the directories matched by patterns keyed by their name,
+// when a namespace is found in multiple directories the first one wins
+func findNamespaces(patterns []string, ext string) map[string]GirFile {
+	found := map[string]GirFile{}
+
+	for _, pattern := range patterns {
+		dirs, _ := filepath.Glob(pattern)
+		for _, dir := range dirs {
+			files, _ := filepath.Glob(filepath.Join(dir, "*"+ext))
+			for _, file := range files {
+				name := strings.TrimSuffix(filepath.Base(file), ext)
+				ns, version, ok := strings.Cut(name, "-")
+				if !ok {
+					continue
+				}
+
+				if _, ok := found[name]; !ok {
+					found[name] = GirFile{ns, version, file}
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// CompareVersions compares namespace versions by their numeric components,
+// so that "3.10" comes after "3.9"
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range min(len(as), len(bs)) {
+		x, xerr := strconv.Atoi(as[i])
+		y, yerr := strconv.Atoi(bs[i])
+		if xerr != nil || yerr != nil {
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if c := cmp.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// FindGirs returns every .gir file in the search path keyed by its name
+func FindGirs() map[string]GirFile {
+	return findNamespaces(GirDirPatterns(), ".gir")
+}
+
+// GirIncludes returns the names of namespaces a .gir file depends on
+func GirIncludes(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var includes []string
+	decoder := xml.NewDecoder(file)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return includes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		el, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch el.Name.Local {
+		case "include":
+			var name, version string
+			for _, attr := range el.Attr {
+				switch attr.Name.Local {
+				case "name":
+					name = attr.Value
+				case "version":
+					version = attr.Value
+				}
+			}
+			includes = append(includes, name+"-"+version)
+		case "namespace":
+			// includes always come before the namespace
+			return includes, nil
+		}
+	}
+}
+
+// GirClosure returns names together with their transitive dependencies
+// which can be found in girs, sorted
+func GirClosure(girs map[string]GirFile, names []string) []string {
+	seen := map[string]bool{}
+	queue := slices.Clone(names)
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		gir, ok := girs[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		includes, err := GirIncludes(gir.Path)
+		if err != nil {
+			Err(err)
+		}
+		queue = append(queue, includes...)
+	}
+
+	closure := make([]string, 0, len(seen))
+	for name := range seen {
+		closure = append(closure, name)
+	}
+	slices.Sort(closure)
+	return closure
+}
@@ -0,0 +1,27 @@
+package lib
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"3.0", "3.0", 0},
+		{"3.10", "3.9", 1},
+		{"2.0", "10.0", -1},
+		{"4.0", "4", 1},
+		{"1.0", "1.0.1", -1},
+		{"0.1", "0.10", -1},
+		{"1.0a", "1.0b", -1},
+	}
+
+	for _, test := range tests {
+		if got := CompareVersions(test.a, test.b); got != test.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := CompareVersions(test.b, test.a); got != -test.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type girCacheEntry struct {
+	Path  string `json:"path"`
+	Mtime int64  `json:"mtime"`
+	Hash  string `json:"hash"`
+}
+
+// GirCache stores generated type declarations per namespace
+// in $XDG_CACHE_HOME/ags/girs, keyed by the path, mtime and hash of the .gir file
+type GirCache struct {
+	Dir       string                   `json:"-"`
+	Generator string                   `json:"generator"`
+	Entries   map[string]girCacheEntry `json:"entries"`
+}
+
+// declarations which do not belong to a namespace, e.g gjs.d.ts
+const girCacheCommon = "common"
+
+func hashFile(path string) string {
+	sum := sha256.Sum256(ReadFile(path))
+	return hex.EncodeToString(sum[:])
+}
+
+// OpenGirCache loads the cache, entries made by a different generator are discarded
+func OpenGirCache(generator string) *GirCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		Err(err)
+	}
+
+	cache := &GirCache{
+		Dir:       filepath.Join(dir, "ags", "girs"),
+		Generator: generator,
+		Entries:   map[string]girCacheEntry{},
+	}
+
+	var stored GirCache
+	if data, err := os.ReadFile(filepath.Join(cache.Dir, "manifest.json")); err == nil {
+		if json.Unmarshal(data, &stored) == nil && stored.Generator == generator {
+			cache.Entries = stored.Entries
+		} else {
+			// output of another generator, start from scratch
+			Rm(cache.Dir)
+		}
+	}
+
+	return cache
+}
+
+func (c *GirCache) Save() {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		Err(err)
+	}
+
+	Mkdir(c.Dir)
+	WriteFile(filepath.Join(c.Dir, "manifest.json"), string(data))
+}
+
+func (c *GirCache) fresh(gir GirFile) bool {
+	entry, ok := c.Entries[gir.Name()]
+	if !ok || entry.Path != gir.Path || !FileExists(filepath.Join(c.Dir, gir.Name())) {
+		return false
+	}
+
+	info, err := os.Stat(gir.Path)
+	if err != nil {
+		return false
+	}
+
+	if entry.Mtime == info.ModTime().UnixNano() {
+		return true
+	}
+
+	// touched but not modified
+	if entry.Hash == hashFile(gir.Path) {
+		entry.Mtime = info.ModTime().UnixNano()
+		c.Entries[gir.Name()] = entry
+		return true
+	}
+
+	return false
+}
+
+// Stale returns the names which have to be regenerated
+func (c *GirCache) Stale(girs map[string]GirFile, names []string) []string {
+	return slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		return c.fresh(girs[name])
+	})
+}
+
+// the namespace a generated file belongs to, files are named
+// after the lowercase namespace and version e.g "gtk-4.0.d.ts"
+func girOwner(girs map[string]GirFile, file string) (string, bool) {
+	for name := range girs {
+		prefix := strings.ToLower(name)
+		if strings.HasPrefix(file, prefix+".") || strings.HasPrefix(file, prefix+"-") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Store moves the output of a generator run into the cache
+// and returns the names of namespaces which were stored
+func (c *GirCache) Store(girs map[string]GirFile, outdir string) []string {
+	entries, err := os.ReadDir(outdir)
+	if err != nil {
+		Err(err)
+	}
+
+	var stored []string
+	for _, e := range entries {
+		if e.Name() == "index.d.ts" {
+			continue
+		}
+
+		owner, ok := girOwner(girs, e.Name())
+		if !ok {
+			owner = girCacheCommon
+		} else if !slices.Contains(stored, owner) {
+			Rm(filepath.Join(c.Dir, owner))
+			stored = append(stored, owner)
+		}
+
+		Mkdir(filepath.Join(c.Dir, owner))
+		Copy(filepath.Join(outdir, e.Name()), filepath.Join(c.Dir, owner, e.Name()))
+	}
+
+	for _, name := range stored {
+		gir := girs[name]
+		info, err := os.Stat(gir.Path)
+		if err != nil {
+			Err(err)
+		}
+
+		c.Entries[name] = girCacheEntry{
+			Path:  gir.Path,
+			Mtime: info.ModTime().UnixNano(),
+			Hash:  hashFile(gir.Path),
+		}
+	}
+
+	return stored
+}
+
+// Restore writes the cached declarations of names into dest
+// together with an index.d.ts referencing all of them
+func (c *GirCache) Restore(names []string, dest string) {
+	Rm(dest)
+	Mkdir(dest)
+
+	for _, dir := range append([]string{girCacheCommon}, names...) {
+		if src := filepath.Join(c.Dir, dir); FileExists(src) {
+			Copy(src, dest)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dest, "*.d.ts"))
+	if err != nil {
+		Err(err)
+	}
+
+	var index strings.Builder
+	for _, file := range files {
+		index.WriteString(`/// <reference path="./` + filepath.Base(file) + `" />` + "\n")
+	}
+
+	WriteFile(filepath.Join(dest, "index.d.ts"), index.String())
+}
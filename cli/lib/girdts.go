@@ -0,0 +1,714 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var reservedWords = []string{
+	"arguments", "await", "break", "case", "catch", "class", "const", "continue",
+	"debugger", "default", "delete", "do", "else", "enum", "eval", "export",
+	"extends", "false", "finally", "for", "function", "if", "implements", "import",
+	"in", "instanceof", "interface", "let", "new", "null", "package", "private",
+	"protected", "public", "return", "static", "super", "switch", "this", "throw",
+	"true", "try", "typeof", "var", "void", "while", "with", "yield",
+}
+
+var numberTypes = []string{
+	"gint", "guint", "gint8", "guint8", "gint16", "guint16", "gint32", "guint32",
+	"gint64", "guint64", "gshort", "gushort", "glong", "gulong", "gsize", "gssize",
+	"gfloat", "gdouble", "gchar", "guchar", "long double", "goffset", "gintptr",
+	"guintptr", "time_t", "off_t", "pid_t", "uid_t", "int", "double", "float",
+}
+
+// declarations gjs adds to GObject on top of what is in the .gir file
+const gobjectExtras = `    export type GType<T = unknown> = {
+        __type__(arg: never): T
+        name: string
+    }
+
+    export type SignalCallback<Emitter, Fn> = Fn extends (...args: infer Args) => infer R
+        ? (emitter: Emitter, ...args: Args) => R
+        : never
+`
+
+const gobjectObjectExtras = `        connect<S extends keyof this["$signals"]>(
+            signal: S,
+            callback: SignalCallback<this, this["$signals"][S]>,
+        ): number
+        connect(signal: string, callback: (...args: any[]) => any): number
+        connect_after<S extends keyof this["$signals"]>(
+            signal: S,
+            callback: SignalCallback<this, this["$signals"][S]>,
+        ): number
+        connect_after(signal: string, callback: (...args: any[]) => any): number
+        emit(signal: string, ...args: any[]): void
+        disconnect(id: number): void
+`
+
+type dtsWriter struct {
+	girs    map[string]GirFile
+	repo    *girRepository
+	ns      string
+	imports map[string]bool
+	out     strings.Builder
+
+	// namespaces referenced in extends clauses of classes, which need a value import
+	values map[string]bool
+	// repositories of other namespaces, used to look up ancestors
+	repos map[string]*girRepository
+}
+
+func identifier(name string) string {
+	name = strings.ReplaceAll(name, "-", "_")
+	if name == "" {
+		return "arg"
+	}
+	if unicode.IsDigit(rune(name[0])) {
+		return "_" + name
+	}
+	if slices.Contains(reservedWords, name) {
+		return name + "_"
+	}
+	return name
+}
+
+func camelCase(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' })
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func (w *dtsWriter) line(indent int, format string, args ...any) {
+	w.out.WriteString(strings.Repeat("    ", indent))
+	fmt.Fprintf(&w.out, format, args...)
+	w.out.WriteString("\n")
+}
+
+func (w *dtsWriter) doc(indent int, doc string) {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return
+	}
+
+	w.line(indent, "/**")
+	for l := range strings.SplitSeq(doc, "\n") {
+		l = strings.ReplaceAll(l, "*/", "*\\/")
+		w.line(indent, "%s", strings.TrimRight(" * "+l, " "))
+	}
+	w.line(indent, " */")
+}
+
+// reference a type by name, qualified names of other namespaces are imported
+func (w *dtsWriter) ref(name string) string {
+	ns, local, ok := strings.Cut(name, ".")
+	if !ok {
+		return name
+	}
+	if ns == w.ns {
+		return local
+	}
+	w.imports[ns] = true
+	return name
+}
+
+// reference a class which is extended, which can not be a type-only import
+func (w *dtsWriter) valueRef(name string) string {
+	if ns, _, ok := strings.Cut(name, "."); ok && ns != w.ns {
+		w.values[ns] = true
+	}
+	return w.ref(name)
+}
+
+func (w *dtsWriter) gtype(name string) string {
+	if w.ns == "GObject" {
+		return "GType<" + name + ">"
+	}
+	return w.ref("GObject.GType") + "<" + name + ">"
+}
+
+func arrayOf(t string) string {
+	if strings.ContainsAny(t, " |") {
+		return "(" + t + ")[]"
+	}
+	return t + "[]"
+}
+
+func (w *dtsWriter) typeOf(t *girType, a *girArray) string {
+	if a != nil {
+		if a.Name == "GLib.ByteArray" || (a.Type != nil && (a.Type.Name == "guint8" || a.Type.Name == "gint8")) {
+			return "Uint8Array"
+		}
+		return arrayOf(w.typeOf(a.Type, a.Array))
+	}
+
+	if t == nil {
+		return "any"
+	}
+
+	switch {
+	case t.Name == "none":
+		return "void"
+	case t.Name == "gboolean":
+		return "boolean"
+	case slices.Contains(numberTypes, t.Name):
+		return "number"
+	case t.Name == "utf8" || t.Name == "filename" || t.Name == "gunichar":
+		return "string"
+	case t.Name == "gpointer" || t.Name == "gconstpointer" || t.Name == "va_list" || t.Name == "":
+		return "any"
+	case t.Name == "GType":
+		if w.ns == "GObject" {
+			return "GType"
+		}
+		return w.ref("GObject.GType")
+	case t.Name == "GLib.List" || t.Name == "GLib.SList" || t.Name == "GLib.PtrArray":
+		if len(t.Types) > 0 {
+			return arrayOf(w.typeOf(&t.Types[0], nil))
+		}
+		return "any[]"
+	case t.Name == "GLib.HashTable":
+		if len(t.Types) == 2 {
+			return "{ [key: string]: " + w.typeOf(&t.Types[1], nil) + " }"
+		}
+		return "{ [key: string]: any }"
+	}
+
+	return w.ref(t.Name)
+}
+
+func nullable(t, flag string) string {
+	if flag == "1" && t != "any" && t != "void" {
+		return t + " | null"
+	}
+	return t
+}
+
+// returns the parameter list and return type as seen from gjs,
+// ok is false if the function can not be called from gjs
+func (w *dtsWriter) signature(fn girFunction) (string, string, bool) {
+	if fn.Introspectable == "0" || fn.ShadowedBy != "" {
+		return "", "", false
+	}
+
+	hidden := map[int]bool{}
+	hide := func(index string) {
+		if i, err := strconv.Atoi(index); err == nil {
+			hidden[i] = true
+		}
+	}
+
+	if a := fn.ReturnValue.Array; a != nil && a.Length != "" {
+		hide(a.Length)
+	}
+
+	for i, p := range fn.Parameters {
+		if p.Varargs != nil {
+			return "", "", false
+		}
+		// user data of callbacks points to itself
+		if p.Closure == strconv.Itoa(i) {
+			hidden[i] = true
+		}
+		if p.Array != nil && p.Array.Length != "" {
+			hide(p.Array.Length)
+		}
+		if p.Type == nil || p.Type.Name != "gpointer" {
+			hide(p.Closure)
+			hide(p.Destroy)
+		}
+	}
+
+	var params, outs []string
+	for i, p := range fn.Parameters {
+		if hidden[i] {
+			continue
+		}
+
+		t := w.typeOf(p.Type, p.Array)
+		if p.Direction == "out" || p.Direction == "inout" {
+			outs = append(outs, nullable(t, p.Nullable))
+		}
+		if p.Direction != "out" {
+			params = append(params, identifier(p.Name)+": "+nullable(t, p.Nullable))
+		}
+	}
+
+	ret := nullable(w.typeOf(fn.ReturnValue.Type, fn.ReturnValue.Array), fn.ReturnValue.Nullable)
+	if ret != "void" {
+		outs = append([]string{ret}, outs...)
+	}
+
+	switch len(outs) {
+	case 0:
+		ret = "void"
+	case 1:
+		ret = outs[0]
+	default:
+		ret = "[" + strings.Join(outs, ", ") + "]"
+	}
+
+	return strings.Join(params, ", "), ret, true
+}
+
+func memberName(fn girFunction) string {
+	if fn.Shadows != "" {
+		return fn.Shadows
+	}
+	return fn.Name
+}
+
+func (w *dtsWriter) function(indent int, prefix string, fn girFunction) bool {
+	params, ret, ok := w.signature(fn)
+	if !ok {
+		return false
+	}
+
+	w.doc(indent, fn.Doc)
+	w.line(indent, "%s%s(%s): %s", prefix, memberName(fn), params, ret)
+	return true
+}
+
+// a member which is also declared by an ancestor gets a catch-all overload,
+// otherwise the differing signatures would make the class incompatible with its parent
+func (w *dtsWriter) member(indent int, prefix string, fn girFunction, inherited map[string]bool) {
+	name := strings.TrimPrefix(prefix, "static ") + memberName(fn)
+	if w.function(indent, prefix, fn) && inherited[name] {
+		w.line(indent, "%s%s(...args: any[]): any", prefix, memberName(fn))
+	}
+}
+
+// repository of a namespace, nil if there is no .gir file for it
+func (w *dtsWriter) repository(ns string) *girRepository {
+	if ns == w.ns {
+		return w.repo
+	}
+	if repo, ok := w.repos[ns]; ok {
+		return repo
+	}
+
+	var repo *girRepository
+	if version, ok := w.importVersion(ns); ok {
+		if gir, ok := w.girs[ns+"-"+version]; ok {
+			repo, _ = readGir(gir.Path)
+		}
+	}
+	w.repos[ns] = repo
+	return repo
+}
+
+// names of the instance and static members declared by the ancestors of a class,
+// vfuncs are prefixed with "vfunc_"
+func (w *dtsWriter) inherited(c girClass) (methods, statics map[string]bool) {
+	methods, statics = map[string]bool{}, map[string]bool{}
+
+	ns := w.ns
+	for c.Parent != "" {
+		parentNs, name := ns, c.Parent
+		if qualifier, local, ok := strings.Cut(c.Parent, "."); ok {
+			parentNs, name = qualifier, local
+		}
+
+		repo := w.repository(parentNs)
+		if repo == nil {
+			return
+		}
+		i := slices.IndexFunc(repo.Namespace.Classes, func(c girClass) bool { return c.Name == name })
+		if i < 0 {
+			return
+		}
+
+		ns, c = parentNs, repo.Namespace.Classes[i]
+		for _, fn := range c.Methods {
+			methods[memberName(fn)] = true
+		}
+		for _, fn := range c.VirtualMethods {
+			methods["vfunc_"+memberName(fn)] = true
+		}
+		for _, fn := range append(slices.Clone(c.Constructors), c.Functions...) {
+			statics[memberName(fn)] = true
+		}
+		if ns == "GObject" && c.Name == "Object" {
+			for _, name := range []string{"connect", "connect_after", "emit", "disconnect"} {
+				methods[name] = true
+			}
+		}
+	}
+	return
+}
+
+func (w *dtsWriter) signals(indent int, c girClass, extends []string) {
+	w.line(indent, "interface SignalSignatures%s {", extendsClause(extends, ".SignalSignatures"))
+	for _, s := range c.Signals {
+		params, ret, ok := w.signature(s)
+		if ok {
+			w.line(indent+1, "%q: (%s) => %s", s.Name, params, ret)
+		}
+	}
+
+	paramSpec := "ParamSpec"
+	if w.ns != "GObject" {
+		paramSpec = w.ref("GObject.ParamSpec")
+	}
+	for _, p := range c.Properties {
+		w.line(indent+1, "%q: (pspec: %s) => void", "notify::"+p.Name, paramSpec)
+	}
+	w.line(indent, "}")
+}
+
+func (w *dtsWriter) constructorProps(indent int, c girClass, extends []string) {
+	w.line(indent, "interface ConstructorProps%s {", extendsClause(extends, ".ConstructorProps"))
+	for _, p := range c.Properties {
+		if p.Writable != "1" && p.ConstructOnly != "1" {
+			continue
+		}
+
+		t := w.typeOf(p.Type, p.Array)
+		w.line(indent+1, "%s: %s", identifier(p.Name), t)
+		if camel := camelCase(p.Name); camel != identifier(p.Name) {
+			w.line(indent+1, "%s: %s", camel, t)
+		}
+	}
+	w.line(indent, "}")
+}
+
+func extendsClause(names []string, suffix string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	refs := make([]string, len(names))
+	for i, name := range names {
+		refs[i] = name + suffix
+	}
+	return " extends " + strings.Join(refs, ", ")
+}
+
+func (w *dtsWriter) properties(indent int, props []girProperty, accessors bool) {
+	for _, p := range props {
+		if p.Readable == "0" {
+			continue
+		}
+
+		t := w.typeOf(p.Type, p.Array)
+		readonly := p.Writable != "1" || p.ConstructOnly == "1"
+
+		names := []string{identifier(p.Name)}
+		if camel := camelCase(p.Name); camel != names[0] {
+			names = append(names, camel)
+		}
+
+		w.doc(indent, p.Doc)
+		for _, name := range names {
+			switch {
+			case !accessors && readonly:
+				w.line(indent, "readonly %s: %s", name, t)
+			case !accessors:
+				w.line(indent, "%s: %s", name, t)
+			default:
+				w.line(indent, "get %s(): %s", name, t)
+				if !readonly {
+					w.line(indent, "set %s(val: %s)", name, t)
+				}
+			}
+		}
+	}
+}
+
+func (w *dtsWriter) class(c girClass) {
+	if c.Introspectable == "0" {
+		return
+	}
+
+	var parents, ifaces []string
+	if c.Parent != "" {
+		parents = []string{w.valueRef(c.Parent)}
+	}
+	for _, i := range c.Implements {
+		ifaces = append(ifaces, w.ref(i.Name))
+	}
+	methods, statics := w.inherited(c)
+
+	// the class is merged with an interface extending ifaces,
+	// so signals have to be compatible with the ones of ifaces too
+	w.line(1, "export namespace %s {", c.Name)
+	w.signals(2, c, append(slices.Clone(parents), ifaces...))
+	w.constructorProps(2, c, append(slices.Clone(parents), ifaces...))
+	w.line(1, "}")
+	w.line(0, "")
+
+	if len(ifaces) > 0 {
+		w.line(1, "export interface %s extends %s {}", c.Name, strings.Join(ifaces, ", "))
+		w.line(0, "")
+	}
+
+	abstract := ""
+	if c.Abstract == "1" {
+		abstract = "abstract "
+	}
+
+	w.doc(1, c.Doc)
+	w.line(1, "export %sclass %s%s {", abstract, c.Name, extendsClause(parents, ""))
+	w.line(2, "static $gtype: %s", w.gtype(c.Name))
+	w.line(2, "$signals: %s.SignalSignatures", c.Name)
+	w.line(0, "")
+	w.line(2, "constructor(properties?: Partial<%s.ConstructorProps>, ...args: any[])", c.Name)
+
+	for _, fn := range c.Constructors {
+		fn.ReturnValue.Nullable = ""
+		w.member(2, "static ", fn, statics)
+	}
+
+	w.properties(2, c.Properties, true)
+
+	if w.ns == "GObject" && c.Name == "Object" {
+		w.out.WriteString(gobjectObjectExtras)
+	}
+
+	for _, fn := range c.Functions {
+		w.member(2, "static ", fn, statics)
+	}
+	for _, fn := range c.Methods {
+		w.member(2, "", fn, methods)
+	}
+	for _, fn := range c.VirtualMethods {
+		w.member(2, "vfunc_", fn, methods)
+	}
+
+	w.line(1, "}")
+	w.line(0, "")
+}
+
+func (w *dtsWriter) iface(c girClass) {
+	if c.Introspectable == "0" {
+		return
+	}
+
+	var prereqs []string
+	for _, p := range c.Prerequisites {
+		prereqs = append(prereqs, w.ref(p.Name))
+	}
+
+	w.line(1, "export namespace %s {", c.Name)
+	w.signals(2, c, prereqs)
+	w.constructorProps(2, c, prereqs)
+	w.line(1, "}")
+	w.line(0, "")
+
+	w.doc(1, c.Doc)
+	w.line(1, "export interface %s%s {", c.Name, extendsClause(prereqs, ""))
+	w.line(2, "$signals: %s.SignalSignatures", c.Name)
+	w.properties(2, c.Properties, false)
+	for _, fn := range c.Methods {
+		w.function(2, "", fn)
+	}
+	for _, fn := range c.VirtualMethods {
+		w.function(2, "vfunc_", fn)
+	}
+	w.line(1, "}")
+	w.line(0, "")
+
+	w.line(1, "export const %s: {", c.Name)
+	w.line(2, "$gtype: %s", w.gtype(c.Name))
+	w.line(2, "prototype: %s", c.Name)
+	for _, fn := range c.Functions {
+		w.function(2, "", fn)
+	}
+	w.line(1, "}")
+	w.line(0, "")
+}
+
+func (w *dtsWriter) record(c girClass) {
+	if c.Introspectable == "0" || c.GTypeStructFor != "" {
+		return
+	}
+
+	w.doc(1, c.Doc)
+	w.line(1, "export class %s {", c.Name)
+	w.line(2, "static $gtype: %s", w.gtype(c.Name))
+	w.line(0, "")
+	w.line(2, "constructor(properties?: Partial<{ [key: string]: any }>)")
+
+	methods := map[string]bool{}
+	for _, fn := range c.Methods {
+		methods[memberName(fn)] = true
+	}
+
+	for _, f := range c.Fields {
+		if f.Private == "1" || f.Readable == "0" || (f.Type == nil && f.Array == nil) || methods[f.Name] {
+			continue
+		}
+		w.line(2, "%s: %s", identifier(f.Name), w.typeOf(f.Type, f.Array))
+	}
+
+	for _, fn := range c.Constructors {
+		fn.ReturnValue.Nullable = ""
+		w.function(2, "static ", fn)
+	}
+	for _, fn := range c.Functions {
+		w.function(2, "static ", fn)
+	}
+	for _, fn := range c.Methods {
+		w.function(2, "", fn)
+	}
+
+	w.line(1, "}")
+	w.line(0, "")
+}
+
+func (w *dtsWriter) enum(e girEnum) {
+	w.doc(1, e.Doc)
+	w.line(1, "export enum %s {", e.Name)
+	for _, m := range e.Members {
+		w.doc(2, m.Doc)
+		w.line(2, "%s = %s,", identifier(strings.ToUpper(m.Name)), m.Value)
+	}
+	w.line(1, "}")
+	w.line(0, "")
+
+	if len(e.Functions) > 0 {
+		w.line(1, "export namespace %s {", e.Name)
+		for _, fn := range e.Functions {
+			w.function(2, "function ", fn)
+		}
+		w.line(1, "}")
+		w.line(0, "")
+	}
+}
+
+func (w *dtsWriter) namespace() {
+	ns := w.repo.Namespace
+
+	if w.ns == "GObject" {
+		w.out.WriteString(gobjectExtras)
+	}
+
+	for _, c := range ns.Constants {
+		w.doc(1, c.Doc)
+		w.line(1, "export const %s: %s", identifier(c.Name), w.typeOf(c.Type, c.Array))
+	}
+	for _, a := range ns.Aliases {
+		w.line(1, "export type %s = %s", a.Name, w.typeOf(a.Type, a.Array))
+	}
+	for _, cb := range ns.Callbacks {
+		if params, ret, ok := w.signature(cb); ok {
+			w.line(1, "export type %s = (%s) => %s", cb.Name, params, ret)
+		}
+	}
+	for _, fn := range ns.Functions {
+		fn.Name = identifier(fn.Name)
+		w.function(1, "export function ", fn)
+	}
+	w.line(0, "")
+
+	for _, e := range append(slices.Clone(ns.Enums), ns.Bitfields...) {
+		w.enum(e)
+	}
+	for _, c := range ns.Interfaces {
+		w.iface(c)
+	}
+	for _, c := range ns.Classes {
+		w.class(c)
+	}
+	for _, c := range append(slices.Clone(ns.Records), ns.Unions...) {
+		w.record(c)
+	}
+}
+
+// version of an imported namespace, preferring the ones the .gir file includes
+func (w *dtsWriter) importVersion(ns string) (string, bool) {
+	for _, inc := range w.repo.Includes {
+		if inc.Name == ns {
+			return inc.Version, true
+		}
+	}
+
+	var versions []string
+	for _, gir := range w.girs {
+		if gir.Namespace == ns {
+			versions = append(versions, gir.Version)
+		}
+	}
+	if len(versions) == 0 {
+		return "", false
+	}
+
+	slices.SortFunc(versions, CompareVersions)
+	return versions[len(versions)-1], true
+}
+
+// GenerateDts generates the declarations of a namespace from its .gir file.
+// When unversioned is true the namespace is also declared as "gi://Name"
+func GenerateDts(girs map[string]GirFile, name string, unversioned bool) (string, error) {
+	gir, ok := girs[name]
+	if !ok {
+		return "", fmt.Errorf("no .gir file found for %s", name)
+	}
+
+	repo, err := readGir(gir.Path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", gir.Path, err)
+	}
+
+	w := &dtsWriter{
+		girs:    girs,
+		repo:    repo,
+		ns:      repo.Namespace.Name,
+		imports: map[string]bool{},
+		values:  map[string]bool{},
+		repos:   map[string]*girRepository{},
+	}
+	w.namespace()
+
+	module := fmt.Sprintf("gi://%s?version=%s", w.ns, repo.Namespace.Version)
+
+	var dts strings.Builder
+	fmt.Fprintf(&dts, "// generated by ags from %s\n\n", filepath.Base(gir.Path))
+	fmt.Fprintf(&dts, "declare module %q {\n", module)
+
+	imports := make([]string, 0, len(w.imports))
+	for ns := range w.imports {
+		imports = append(imports, ns)
+	}
+	slices.Sort(imports)
+
+	for _, ns := range imports {
+		version, ok := w.importVersion(ns)
+		if !ok {
+			continue
+		}
+
+		if w.values[ns] {
+			fmt.Fprintf(&dts, "    import %s from \"gi://%s?version=%s\"\n", ns, ns, version)
+		} else {
+			fmt.Fprintf(&dts, "    import type %s from \"gi://%s?version=%s\"\n", ns, ns, version)
+		}
+	}
+
+	if len(imports) > 0 {
+		dts.WriteString("\n")
+	}
+
+	fmt.Fprintf(&dts, "    export namespace %s {\n", w.ns)
+	for l := range strings.Lines(strings.Trim(w.out.String(), "\n") + "\n") {
+		if strings.TrimSpace(l) == "" {
+			dts.WriteString("\n")
+		} else {
+			dts.WriteString("    " + l)
+		}
+	}
+	fmt.Fprintf(&dts, "    }\n\n    export default %s\n}\n", w.ns)
+
+	if unversioned {
+		fmt.Fprintf(&dts, "\ndeclare module \"gi://%s\" {\n", w.ns)
+		fmt.Fprintf(&dts, "    import %s from %q\n    export default %s\n}\n", w.ns, module, w.ns)
+	}
+
+	return dts.String(), nil
+}
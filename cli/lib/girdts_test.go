@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateDts(t *testing.T) {
+	girs := findNamespaces([]string{"testdata"}, ".gir")
+
+	tests := []struct {
+		name        string
+		unversioned bool
+		contains    []string
+		excludes    []string
+	}{
+		{
+			name: "Widgets-1.0",
+			contains: []string{
+				// classes of other namespaces are extended, which needs a value import
+				`    import GObject from "gi://GObject?version=2.0"`,
+				`    import type GLib from "gi://GLib?version=2.0"`,
+				"export abstract class Widget extends GObject.InitiallyUnowned {",
+				"export class Button extends Widget {",
+				"export interface Widget extends Buildable {}",
+				"export interface Buildable extends GObject.Object {",
+				"interface SignalSignatures extends GObject.InitiallyUnowned.SignalSignatures, Buildable.SignalSignatures {",
+				"interface ConstructorProps extends GObject.InitiallyUnowned.ConstructorProps, Buildable.ConstructorProps {",
+				`"notify::css-name": (pspec: GObject.ParamSpec) => void`,
+				"get_state(): GLib.Variant",
+				"get_id(): string | null",
+				"get css_name(): string",
+				"get cssName(): string",
+				"set label(val: string)",
+				// members declared by an ancestor get a compatible overload
+				"static new(label: string, size: number): Button\n            static new(...args: any[]): any",
+				"connect(target: Widget): boolean\n            connect(...args: any[]): any",
+				"vfunc_dispose(): void\n            vfunc_dispose(...args: any[]): any",
+				"length(): number",
+				"    export default Widgets\n}\n",
+			},
+			excludes: []string{
+				"@ts-nocheck",
+				"import type GObject",
+				"set css_name",
+				"set_label(...args: any[]): any",
+				"length: number",
+				`declare module "gi://Widgets" {`,
+			},
+		},
+		{
+			name:        "GObject-2.0",
+			unversioned: true,
+			contains: []string{
+				`declare module "gi://GObject?version=2.0" {`,
+				"export type GType<T = unknown> = {",
+				"static $gtype: GType<Object>",
+				"export class InitiallyUnowned extends Object {",
+				"export abstract class ParamSpec {",
+				`"notify": (pspec: ParamSpec) => void`,
+				"emit(signal: string, ...args: any[]): void",
+				"static new(): Object\n",
+				`declare module "gi://GObject" {`,
+				`    import GObject from "gi://GObject?version=2.0"`,
+			},
+			excludes: []string{
+				"@ts-nocheck",
+				"static new(...args: any[]): any",
+				"GObject.GType",
+			},
+		},
+		{
+			name: "GLib-2.0",
+			contains: []string{
+				`    import type GObject from "gi://GObject?version=2.0"`,
+				"static $gtype: GObject.GType<Variant>",
+				"print(type_annotate: boolean): string",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dts, err := GenerateDts(girs, test.name, test.unversioned)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			for _, s := range test.contains {
+				if !strings.Contains(dts, s) {
+					t.Errorf("missing %q in\n%s", s, dts)
+				}
+			}
+			for _, s := range test.excludes {
+				if strings.Contains(dts, s) {
+					t.Errorf("unexpected %q in\n%s", s, dts)
+				}
+			}
+		})
+	}
+
+	if _, err := GenerateDts(girs, "Missing-1.0", false); err == nil {
+		t.Errorf("expected an error for a missing namespace")
+	}
+}
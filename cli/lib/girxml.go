@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+)
+
+// subset of the GIR format which is needed to generate declarations
+// https://gitlab.gnome.org/GNOME/gobject-introspection/-/blob/main/docs/gir-1.2.rnc
+
+type girRepository struct {
+	Includes  []girInclude `xml:"include"`
+	Namespace girNamespace `xml:"namespace"`
+}
+
+type girInclude struct {
+	Name    string `xml:"name,attr"`
+	Version string `xml:"version,attr"`
+}
+
+type girNamespace struct {
+	Name       string        `xml:"name,attr"`
+	Version    string        `xml:"version,attr"`
+	Classes    []girClass    `xml:"class"`
+	Interfaces []girClass    `xml:"interface"`
+	Records    []girClass    `xml:"record"`
+	Unions     []girClass    `xml:"union"`
+	Enums      []girEnum     `xml:"enumeration"`
+	Bitfields  []girEnum     `xml:"bitfield"`
+	Functions  []girFunction `xml:"function"`
+	Callbacks  []girFunction `xml:"callback"`
+	Constants  []girConstant `xml:"constant"`
+	Aliases    []girConstant `xml:"alias"`
+}
+
+type girDoc struct {
+	Doc string `xml:"doc"`
+}
+
+type girClass struct {
+	girDoc
+	Name           string        `xml:"name,attr"`
+	Parent         string        `xml:"parent,attr"`
+	Abstract       string        `xml:"abstract,attr"`
+	Introspectable string        `xml:"introspectable,attr"`
+	GTypeStructFor string        `xml:"http://www.gtk.org/introspection/glib/1.0 is-gtype-struct-for,attr"`
+	Implements     []girInclude  `xml:"implements"`
+	Prerequisites  []girInclude  `xml:"prerequisite"`
+	Constructors   []girFunction `xml:"constructor"`
+	Methods        []girFunction `xml:"method"`
+	Functions      []girFunction `xml:"function"`
+	VirtualMethods []girFunction `xml:"virtual-method"`
+	Properties     []girProperty `xml:"property"`
+	Signals        []girFunction `xml:"http://www.gtk.org/introspection/glib/1.0 signal"`
+	Fields         []girField    `xml:"field"`
+}
+
+type girEnum struct {
+	girDoc
+	Name      string        `xml:"name,attr"`
+	Members   []girMember   `xml:"member"`
+	Functions []girFunction `xml:"function"`
+}
+
+type girMember struct {
+	girDoc
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type girFunction struct {
+	girDoc
+	Name           string         `xml:"name,attr"`
+	Introspectable string         `xml:"introspectable,attr"`
+	ShadowedBy     string         `xml:"shadowed-by,attr"`
+	Shadows        string         `xml:"shadows,attr"`
+	Throws         string         `xml:"throws,attr"`
+	ReturnValue    girReturnValue `xml:"return-value"`
+	Parameters     []girParameter `xml:"parameters>parameter"`
+}
+
+type girReturnValue struct {
+	Nullable string    `xml:"nullable,attr"`
+	Type     *girType  `xml:"type"`
+	Array    *girArray `xml:"array"`
+}
+
+type girParameter struct {
+	Name      string    `xml:"name,attr"`
+	Direction string    `xml:"direction,attr"`
+	Nullable  string    `xml:"nullable,attr"`
+	Optional  string    `xml:"optional,attr"`
+	Closure   string    `xml:"closure,attr"`
+	Destroy   string    `xml:"destroy,attr"`
+	Type      *girType  `xml:"type"`
+	Array     *girArray `xml:"array"`
+	Varargs   *struct{} `xml:"varargs"`
+}
+
+type girProperty struct {
+	girDoc
+	Name          string    `xml:"name,attr"`
+	Writable      string    `xml:"writable,attr"`
+	Readable      string    `xml:"readable,attr"`
+	Construct     string    `xml:"construct,attr"`
+	ConstructOnly string    `xml:"construct-only,attr"`
+	Type          *girType  `xml:"type"`
+	Array         *girArray `xml:"array"`
+}
+
+type girField struct {
+	Name     string    `xml:"name,attr"`
+	Private  string    `xml:"private,attr"`
+	Readable string    `xml:"readable,attr"`
+	Type     *girType  `xml:"type"`
+	Array    *girArray `xml:"array"`
+}
+
+type girConstant struct {
+	girDoc
+	Name  string    `xml:"name,attr"`
+	Type  *girType  `xml:"type"`
+	Array *girArray `xml:"array"`
+}
+
+type girType struct {
+	Name  string    `xml:"name,attr"`
+	Types []girType `xml:"type"`
+}
+
+type girArray struct {
+	Name   string    `xml:"name,attr"`
+	Length string    `xml:"length,attr"`
+	Type   *girType  `xml:"type"`
+	Array  *girArray `xml:"array"`
+}
+
+func parseGir(r io.Reader) (*girRepository, error) {
+	var repo girRepository
+	if err := xml.NewDecoder(r).Decode(&repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func readGir(path string) (*girRepository, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseGir(file)
+}
@@ -0,0 +1,557 @@
+package lib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type gschemaValue struct {
+	Nick  string `xml:"nick,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gschemaEnum struct {
+	Id     string         `xml:"id,attr"`
+	Values []gschemaValue `xml:"value"`
+}
+
+type gschemaDefault struct {
+	Text    string `xml:",chardata"`
+	L10n    string `xml:"l10n,attr"`
+	Context string `xml:"context,attr"`
+}
+
+type gschemaKey struct {
+	Name    string         `xml:"name,attr"`
+	Type    string         `xml:"type,attr"`
+	Enum    string         `xml:"enum,attr"`
+	Flags   string         `xml:"flags,attr"`
+	Default gschemaDefault `xml:"default"`
+	Summary string         `xml:"summary"`
+	Choices *struct {
+		Choices []struct {
+			Value string `xml:"value,attr"`
+		} `xml:"choice"`
+	} `xml:"choices"`
+	Aliases []struct {
+		Value  string `xml:"value,attr"`
+		Target string `xml:"target,attr"`
+	} `xml:"aliases>alias"`
+	Range *struct {
+		Min string `xml:"min,attr"`
+		Max string `xml:"max,attr"`
+	} `xml:"range"`
+}
+
+type gschemaChild struct {
+	Name   string `xml:"name,attr"`
+	Schema string `xml:"schema,attr"`
+}
+
+type gschemaOverride struct {
+	Name string `xml:"name,attr"`
+	Text string `xml:",chardata"`
+}
+
+type gschema struct {
+	Id            string            `xml:"id,attr"`
+	Path          string            `xml:"path,attr"`
+	GettextDomain string            `xml:"gettext-domain,attr"`
+	Extends       string            `xml:"extends,attr"`
+	ListOf        string            `xml:"list-of,attr"`
+	Keys          []gschemaKey      `xml:"key"`
+	Children      []gschemaChild    `xml:"child"`
+	Overrides     []gschemaOverride `xml:"override"`
+
+	file   string
+	source string
+}
+
+type gschemaList struct {
+	GettextDomain string        `xml:"gettext-domain,attr"`
+	Enums         []gschemaEnum `xml:"enum"`
+	Flags         []gschemaEnum `xml:"flags"`
+	Schemas       []gschema     `xml:"schema"`
+}
+
+// GSchemaError is an invalid schema, Line is 0 when it is unknown
+type GSchemaError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *GSchemaError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// GSchemas are the GSettings schemas of a project
+type GSchemas struct {
+	Files   []string
+	schemas []gschema
+	enums   map[string]gschemaEnum
+	flags   map[string]gschemaEnum
+}
+
+// FindGSchemas returns the *.gschema.xml files of a project
+func FindGSchemas(root string) []string {
+	return globFiles(root, []string{"*.gschema.xml"})
+}
+
+var gschemaKeyName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// line of the first occurrence of an attribute with the given value
+func gschemaLine(source, attr, value string) int {
+	for _, quote := range []string{`"`, `'`} {
+		if i := strings.Index(source, attr+"="+quote+value+quote); i != -1 {
+			return strings.Count(source[:i], "\n") + 1
+		}
+	}
+	return 0
+}
+
+// ParseGSchemas reads and validates schema files
+func ParseGSchemas(files []string) (*GSchemas, error) {
+	s := &GSchemas{Files: files, enums: map[string]gschemaEnum{}, flags: map[string]gschemaEnum{}}
+
+	for _, file := range files {
+		source := string(ReadFile(file))
+
+		var list gschemaList
+		if err := xml.Unmarshal([]byte(source), &list); err != nil {
+			return nil, &GSchemaError{file, 0, err.Error()}
+		}
+
+		for _, e := range append(list.Enums, list.Flags...) {
+			for _, v := range e.Values {
+				if len(v.Nick) < 2 {
+					return nil, &GSchemaError{file, gschemaLine(source, "nick", v.Nick),
+						fmt.Sprintf(`nick "%s" of "%s" has to be at least 2 characters long`, v.Nick, e.Id)}
+				}
+			}
+		}
+
+		for _, e := range list.Enums {
+			s.enums[e.Id] = e
+		}
+		for _, f := range list.Flags {
+			s.flags[f.Id] = f
+		}
+
+		for _, schema := range list.Schemas {
+			if schema.GettextDomain == "" {
+				schema.GettextDomain = list.GettextDomain
+			}
+			schema.file, schema.source = file, source
+
+			if slices.ContainsFunc(s.schemas, func(other gschema) bool { return other.Id == schema.Id }) {
+				return nil, &GSchemaError{file, gschemaLine(source, "id", schema.Id),
+					fmt.Sprintf(`schema "%s" is defined more than once`, schema.Id)}
+			}
+			s.schemas = append(s.schemas, schema)
+		}
+	}
+
+	for i := range s.schemas {
+		if err := s.resolve(&s.schemas[i], nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, schema := range s.schemas {
+		if err := s.validate(schema); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *GSchemas) schema(id string) *gschema {
+	for i := range s.schemas {
+		if s.schemas[i].Id == id {
+			return &s.schemas[i]
+		}
+	}
+	return nil
+}
+
+// copies the keys of the schema it extends and applies the overrides
+func (s *GSchemas) resolve(schema *gschema, seen []string) error {
+	if schema.Extends == "" || slices.Contains(seen, schema.Id) {
+		return nil
+	}
+
+	base := s.schema(schema.Extends)
+	if base == nil {
+		return &GSchemaError{schema.file, gschemaLine(schema.source, "extends", schema.Extends),
+			fmt.Sprintf(`schema "%s" extends unknown schema "%s"`, schema.Id, schema.Extends)}
+	}
+
+	if base.Path != "" {
+		return &GSchemaError{schema.file, gschemaLine(schema.source, "extends", schema.Extends),
+			fmt.Sprintf(`schema "%s" can not extend "%s" as it has a path`, schema.Id, schema.Extends)}
+	}
+
+	if err := s.resolve(base, append(seen, schema.Id)); err != nil {
+		return err
+	}
+
+	for _, key := range base.Keys {
+		if !slices.ContainsFunc(schema.Keys, func(k gschemaKey) bool { return k.Name == key.Name }) {
+			schema.Keys = append(schema.Keys, key)
+		}
+	}
+
+	for _, o := range schema.Overrides {
+		i := slices.IndexFunc(schema.Keys, func(k gschemaKey) bool { return k.Name == o.Name })
+		if i == -1 {
+			return &GSchemaError{schema.file, gschemaLine(schema.source, "name", o.Name),
+				fmt.Sprintf(`override of unknown key "%s"`, o.Name)}
+		}
+		schema.Keys[i].Default.Text = o.Text
+	}
+
+	schema.Overrides = nil
+	return nil
+}
+
+// type of a key, enums are stored as strings and flags as arrays of strings
+func (k gschemaKey) typ() string {
+	switch {
+	case k.Enum != "":
+		return "s"
+	case k.Flags != "":
+		return "as"
+	}
+	return k.Type
+}
+
+func (s *GSchemas) validate(schema gschema) error {
+	fail := func(attr, value, format string, args ...any) error {
+		return &GSchemaError{schema.file, gschemaLine(schema.source, attr, value), fmt.Sprintf(format, args...)}
+	}
+
+	if schema.Path != "" && (!strings.HasPrefix(schema.Path, "/") || !strings.HasSuffix(schema.Path, "/") || strings.Contains(schema.Path, "//")) {
+		return fail("path", schema.Path, `path "%s" has to start and end with a slash and must not contain "//"`, schema.Path)
+	}
+
+	var names []string
+	for _, key := range schema.Keys {
+		if !gschemaKeyName.MatchString(key.Name) || strings.Contains(key.Name, "--") || strings.HasSuffix(key.Name, "-") || len(key.Name) > 1024 {
+			return fail("name", key.Name, `invalid key name "%s", names consist of lowercase letters, digits and single dashes`, key.Name)
+		}
+		if slices.Contains(names, key.Name) {
+			return fail("name", key.Name, `key "%s" is defined more than once`, key.Name)
+		}
+		names = append(names, key.Name)
+
+		if _, err := s.keyValue(key); err != nil {
+			return fail("name", key.Name, `key "%s": %v`, key.Name, err)
+		}
+	}
+
+	for _, child := range schema.Children {
+		if slices.Contains(names, child.Name+"/") {
+			return fail("name", child.Name, `child "%s" is defined more than once`, child.Name)
+		}
+		names = append(names, child.Name+"/")
+
+		if s.schema(child.Schema) == nil {
+			return fail("schema", child.Schema, `child "%s" refers to unknown schema "%s"`, child.Name, child.Schema)
+		}
+	}
+
+	if schema.ListOf != "" && s.schema(schema.ListOf) == nil {
+		return fail("list-of", schema.ListOf, `unknown schema "%s"`, schema.ListOf)
+	}
+
+	return nil
+}
+
+// strinfo is the format of enums, flags and choices in compiled schemas,
+// a word of the value followed by at least two words of the 0xff prefixed string
+// padded with nuls and terminated by 0xff, aliases are prefixed with 0xfe
+// and their value is the index of the word of their target
+func strinfoAppend(strinfo []byte, prefix byte, s string, value uint32) []byte {
+	strinfo = append(strinfo, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+	start := len(strinfo)
+	strinfo = append(strinfo, prefix)
+	strinfo = append(strinfo, s...)
+	strinfo = append(strinfo, 0)
+	for (len(strinfo)+1)%4 != 0 || len(strinfo)-start < 7 {
+		strinfo = append(strinfo, 0)
+	}
+	return append(strinfo, 0xff)
+}
+
+// word index of the value of the string in a strinfo
+func strinfoIndex(strinfo []byte, s string) int {
+	needle := append(append([]byte{0xff}, s...), 0)
+	for i := 4; i+len(needle) <= len(strinfo); i += 4 {
+		if string(strinfo[i:i+len(needle)]) == string(needle) {
+			return i/4 - 1
+		}
+	}
+	return -1
+}
+
+func strinfoWords(strinfo []byte) []any {
+	words := make([]any, len(strinfo)/4)
+	for i := range words {
+		b := strinfo[i*4:]
+		words[i] = int64(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+	}
+	return words
+}
+
+// keyValue returns the type and value of a key as stored in gschemas.compiled,
+// a tuple of the default value followed by its restrictions, see glib-compile-schemas.c
+func (s *GSchemas) keyValue(key gschemaKey) (gvariant, error) {
+	typ := key.typ()
+	if (key.Type != "") == (key.Enum != "" || key.Flags != "") || key.Enum != "" && key.Flags != "" {
+		return gvariant{}, fmt.Errorf("exactly one of type, enum or flags has to be set")
+	}
+	if !gvTypeValid(typ) {
+		return gvariant{}, fmt.Errorf(`invalid type "%s"`, typ)
+	}
+
+	def, err := gvParse(typ, key.Default.Text)
+	if err != nil {
+		return gvariant{}, fmt.Errorf("invalid default value: %v", err)
+	}
+
+	tupleType, tuple := "("+typ, []any{def}
+
+	if key.Default.L10n != "" {
+		var category byte
+		switch key.Default.L10n {
+		case "messages":
+			category = 'm'
+		case "time":
+			category = 't'
+		default:
+			return gvariant{}, fmt.Errorf(`unknown l10n category "%s"`, key.Default.L10n)
+		}
+
+		text := strings.TrimSpace(key.Default.Text)
+		if key.Default.Context != "" {
+			text = key.Default.Context + "\x04" + text
+		}
+		tupleType += "(y(ys))"
+		tuple = append(tuple, []any{int64('l'), []any{int64(category), text}})
+	}
+
+	var strinfo []byte
+	var nicks []string
+	code := int64('c')
+
+	if key.Enum != "" || key.Flags != "" {
+		enum, ok := s.enums[key.Enum]
+		code = 'e'
+		if key.Flags != "" {
+			enum, ok = s.flags[key.Flags]
+			code = 'f'
+		}
+		if !ok {
+			return gvariant{}, fmt.Errorf(`unknown enum "%s%s"`, key.Enum, key.Flags)
+		}
+		for _, v := range enum.Values {
+			n, err := strconv.ParseInt(v.Value, 0, 64)
+			if err != nil {
+				return gvariant{}, fmt.Errorf(`invalid value "%s" of "%s"`, v.Value, v.Nick)
+			}
+			strinfo = strinfoAppend(strinfo, 0xff, v.Nick, uint32(n))
+			nicks = append(nicks, v.Nick)
+		}
+	}
+
+	if key.Choices != nil {
+		if typ != "s" && typ != "as" && typ != "ms" || key.Enum != "" || key.Flags != "" {
+			return gvariant{}, fmt.Errorf("choices are only supported for string keys")
+		}
+		for _, c := range key.Choices.Choices {
+			strinfo = strinfoAppend(strinfo, 0xff, c.Value, 0)
+			nicks = append(nicks, c.Value)
+		}
+	}
+
+	for _, alias := range key.Aliases {
+		i := strinfoIndex(strinfo, alias.Target)
+		if i == -1 {
+			return gvariant{}, fmt.Errorf(`alias "%s" targets unknown value "%s"`, alias.Value, alias.Target)
+		}
+		strinfo = strinfoAppend(strinfo, 0xfe, alias.Value, uint32(i))
+	}
+
+	if nicks != nil {
+		var values []string
+		switch v := def.(type) {
+		case string:
+			values = []string{v}
+		case []any:
+			for _, s := range v {
+				values = append(values, s.(string))
+			}
+		}
+		for _, v := range values {
+			if !slices.Contains(nicks, v) {
+				return gvariant{}, fmt.Errorf(`default value "%s" is not one of: %s`, v, strings.Join(nicks, ", "))
+			}
+		}
+		tupleType += "(yau)"
+		tuple = append(tuple, []any{code, strinfoWords(strinfo)})
+	}
+
+	if key.Range != nil {
+		min, err := gvParse(typ, key.Range.Min)
+		if err != nil {
+			return gvariant{}, fmt.Errorf("invalid range minimum: %v", err)
+		}
+		max, err := gvParse(typ, key.Range.Max)
+		if err != nil {
+			return gvariant{}, fmt.Errorf("invalid range maximum: %v", err)
+		}
+
+		lower, err := gvCompare(typ, min, def)
+		if err != nil {
+			return gvariant{}, fmt.Errorf("ranges are only supported for numeric keys")
+		}
+		upper, _ := gvCompare(typ, def, max)
+		if order, _ := gvCompare(typ, min, max); order > 0 {
+			return gvariant{}, fmt.Errorf("range minimum is larger than its maximum")
+		}
+		if lower > 0 || upper > 0 {
+			return gvariant{}, fmt.Errorf("default value is not in range %s..%s", key.Range.Min, key.Range.Max)
+		}
+
+		tupleType += "(y(" + typ + typ + "))"
+		tuple = append(tuple, []any{int64('r'), []any{min, max}})
+	}
+
+	return gvariant{tupleType + ")", tuple}, nil
+}
+
+// Compile serializes the schemas into the gschemas.compiled format
+func (s *GSchemas) Compile() []byte {
+	root := &gvdbTable{}
+	for _, schema := range s.schemas {
+		table := root.insertTable(schema.Id)
+		list := table.insert("")
+
+		for _, key := range schema.Keys {
+			value, _ := s.keyValue(key)
+			table.insertValue(key.Name, value.Type, value.Value).setParent(list)
+		}
+
+		for _, child := range schema.Children {
+			table.insertValue(child.Name+"/", "s", child.Schema).setParent(list)
+		}
+
+		if schema.Path != "" {
+			table.insertValue(".path", "s", schema.Path)
+		}
+		if schema.Extends != "" {
+			table.insertValue(".extends", "s", schema.Extends)
+		}
+		if schema.ListOf != "" {
+			table.insertValue(".list-of", "s", schema.ListOf)
+		}
+		if schema.GettextDomain != "" {
+			table.insertValue(".gettext-domain", "s", schema.GettextDomain)
+		}
+	}
+	return gvdbWrite(root)
+}
+
+// CompileGSchemas writes gschemas.compiled into dir, glib-compile-schemas is
+// used when it is installed, otherwise the schemas are compiled by ags
+func CompileGSchemas(schemas *GSchemas, dir string) error {
+	Mkdir(dir)
+
+	if _, err := exec.LookPath("glib-compile-schemas"); err != nil {
+		return os.WriteFile(filepath.Join(dir, "gschemas.compiled"), schemas.Compile(), 0644)
+	}
+
+	// it only compiles the schemas of a single directory
+	src, cleanup := TempDir("ags-schemas-")
+	defer cleanup()
+
+	for _, file := range schemas.Files {
+		Copy(file, filepath.Join(src, filepath.Base(file)))
+	}
+
+	cmd := Exec("glib-compile-schemas", "--strict", "--targetdir", dir, src)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Dts declares the keys of the schemas and the types of their values
+func (s *GSchemas) Dts() string {
+	var dts strings.Builder
+	dts.WriteString("// generated by ags from the GSettings schemas of this project\n")
+	dts.WriteString("declare global {\n")
+	dts.WriteString("  /** keys of the GSettings schemas and the unpacked types of their values */\n")
+	dts.WriteString("  interface GSettingsSchemas {\n")
+
+	for _, schema := range s.schemas {
+		dts.WriteString(fmt.Sprintf("    %s: {\n", strconv.Quote(schema.Id)))
+		for _, key := range schema.Keys {
+			if key.Summary != "" {
+				dts.WriteString(fmt.Sprintf("      /** %s */\n", strings.TrimSpace(key.Summary)))
+			}
+			dts.WriteString(fmt.Sprintf("      %s: %s\n", strconv.Quote(key.Name), s.tsType(key)))
+		}
+		dts.WriteString("    }\n")
+	}
+
+	dts.WriteString("  }\n")
+	dts.WriteString("}\n\n")
+	dts.WriteString("export type SchemaId = keyof GSettingsSchemas\n")
+	dts.WriteString("export type SchemaKey<Id extends SchemaId> = keyof GSettingsSchemas[Id] & string\n")
+	dts.WriteString("export type SchemaValue<Id extends SchemaId, Key extends SchemaKey<Id>> = GSettingsSchemas[Id][Key]\n")
+	return dts.String()
+}
+
+func (s *GSchemas) tsType(key gschemaKey) string {
+	var nicks []string
+	switch {
+	case key.Enum != "":
+		for _, v := range s.enums[key.Enum].Values {
+			nicks = append(nicks, strconv.Quote(v.Nick))
+		}
+	case key.Flags != "":
+		for _, v := range s.flags[key.Flags].Values {
+			nicks = append(nicks, strconv.Quote(v.Nick))
+		}
+		return "Array<" + strings.Join(nicks, " | ") + ">"
+	case key.Choices != nil:
+		for _, c := range key.Choices.Choices {
+			nicks = append(nicks, strconv.Quote(c.Value))
+		}
+		switch key.Type {
+		case "as":
+			return "Array<" + strings.Join(nicks, " | ") + ">"
+		case "ms":
+			nicks = append(nicks, "null")
+		}
+	default:
+		return gvTsType(key.Type)
+	}
+	return strings.Join(nicks, " | ")
+}
+
+// WriteDts writes the declarations to path
+func (s *GSchemas) WriteDts(path string) {
+	writeSourceDts(path, s.Dts())
+}
@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// namespaces which only exist for a single gtk version
+var gtkNamespaces = []string{"Gtk", "Gdk", "Gsk", "Astal", "GdkX11", "GdkWayland"}
+
+var gtkSubpath = regexp.MustCompile(`^(?:ags|gnim)/gtk([34])(?:/|$)`)
+
+type gtkUse struct {
+	Version uint
+	File    string
+	Import  string
+}
+
+// gtk version an import pins, or 0 if it is not version specific
+func gtkImportVersion(imp MetafileImport) uint {
+	if m := gtkSubpath.FindStringSubmatch(imp.Original); m != nil {
+		v, _ := strconv.Atoi(m[1])
+		return uint(v)
+	}
+
+	gi, ok := ParseGiImport(imp.Path)
+	if !ok || !slices.Contains(gtkNamespaces, gi.Namespace) {
+		return 0
+	}
+
+	switch major, _, _ := strings.Cut(gi.Version, "."); major {
+	case "3":
+		return 3
+	case "4":
+		return 4
+	}
+	return 0
+}
+
+// imports of files which the builtin loaders and plugins handle instead of esbuild's script loaders
+var nonScriptImport = regexp.MustCompile(`(?i)\.(css|scss|blp|png|jpe?g|gif|webp|wav|ogg|mp3|ttf|otf|ttc|woff2?|ya?ml|toml|json5?)$`)
+
+var importScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// imports of stylesheets, blueprints, data, assets, custom loaders and ones
+// with a scheme such as gi:// are left external, so that they are not built twice,
+// everything else is resolved by esbuild, dotted names like socket.io-client included
+func resolveOnlyPlugin(loaders []ExecLoader) api.Plugin {
+	var filters []*regexp.Regexp
+	for _, l := range loaders {
+		if re, err := regexp.Compile(l.filter()); err == nil {
+			filters = append(filters, re)
+		}
+	}
+
+	return api.Plugin{
+		Name: "resolve-only",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: ".*"},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					external := importScheme.MatchString(args.Path) || nonScriptImport.MatchString(args.Path) ||
+						slices.ContainsFunc(filters, func(re *regexp.Regexp) bool { return re.MatchString(args.Path) })
+					if args.Kind == api.ResolveEntryPoint || !external {
+						return api.OnResolveResult{}, nil
+					}
+					return api.OnResolveResult{Path: args.Path, External: true}, nil
+				})
+		},
+	}
+}
+
+// InferGtkVersion returns the gtk version the module graph of opts.Infile imports
+// or 0 when no module is version specific, mixing versions is an error
+func InferGtkVersion(opts BundleOpts) uint {
+	opts.Outfile = ""
+	opts.Metafile = true
+	opts.SkipTypelibCheck = true
+	// the jsx runtime depends on the version we are trying to infer
+	opts.jsxPreserve = true
+	opts.resolveOnly = true
+
+	dir := opts.WorkingDirectory
+	if dir == "" {
+		dir = Cwd()
+	}
+	dir, _ = filepath.Abs(dir)
+
+	meta := ParseMetafile(Bundle(opts).Metafile)
+
+	uses := map[uint][]gtkUse{}
+	for file, input := range meta.Inputs {
+		// modules of the ags package are picked by version through their import path
+		if agsJsPackage != "" && strings.HasPrefix(filepath.Join(dir, file), agsJsPackage) {
+			continue
+		}
+
+		for _, imp := range input.Imports {
+			if v := gtkImportVersion(imp); v != 0 {
+				spec := imp.Original
+				if spec == "" {
+					spec = imp.Path
+				}
+				uses[v] = append(uses[v], gtkUse{v, file, spec})
+			}
+		}
+	}
+
+	if len(uses[3]) > 0 && len(uses[4]) > 0 {
+		msg := "modules import both Gtk3 and Gtk4 which can not be loaded together"
+		for _, v := range []uint{3, 4} {
+			slices.SortFunc(uses[v], func(a, b gtkUse) int {
+				return strings.Compare(a.File, b.File)
+			})
+			for _, use := range uses[v] {
+				msg += fmt.Sprintf("\n  %s %s imports %s",
+					Yellow(fmt.Sprintf("gtk%d", v)), Cyan(use.File), use.Import)
+			}
+		}
+		Err(msg)
+	}
+
+	for v := range uses {
+		return v
+	}
+	return 0
+}
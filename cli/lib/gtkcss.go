@@ -0,0 +1,336 @@
+package lib
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// gtkCss checks stylesheets against the subset of css gtk supports,
+// a zero Version disables the check
+type gtkCss struct {
+	Version uint
+	Strict  bool // report unsupported css as errors instead of warnings
+}
+
+var gtkCommonProperties = []string{
+	"all", "color", "opacity", "min-width", "min-height",
+	"font", "font-family", "font-size", "font-style", "font-variant", "font-weight", "font-stretch",
+	"font-kerning", "font-variant-ligatures", "font-variant-position", "font-variant-caps",
+	"font-variant-numeric", "font-variant-alternates", "font-variant-east-asian",
+	"font-feature-settings", "font-variation-settings", "letter-spacing", "-gtk-dpi",
+	"caret-color", "-gtk-secondary-caret-color", "text-shadow",
+	"text-decoration", "text-decoration-line", "text-decoration-color", "text-decoration-style",
+	"-gtk-icon-source", "-gtk-icon-shadow", "-gtk-icon-style", "-gtk-icon-transform", "-gtk-icon-palette",
+	"background", "background-color", "background-clip", "background-origin", "background-size",
+	"background-position", "background-repeat", "background-image", "background-blend-mode",
+	"box-shadow",
+	"margin", "margin-top", "margin-right", "margin-bottom", "margin-left",
+	"padding", "padding-top", "padding-right", "padding-bottom", "padding-left",
+	"border", "border-top", "border-right", "border-bottom", "border-left",
+	"border-width", "border-style", "border-color",
+	"border-top-width", "border-right-width", "border-bottom-width", "border-left-width",
+	"border-top-style", "border-right-style", "border-bottom-style", "border-left-style",
+	"border-top-color", "border-right-color", "border-bottom-color", "border-left-color",
+	"border-radius", "border-top-left-radius", "border-top-right-radius",
+	"border-bottom-right-radius", "border-bottom-left-radius",
+	"border-image", "border-image-source", "border-image-repeat", "border-image-slice", "border-image-width",
+	"outline", "outline-style", "outline-width", "outline-color", "outline-offset",
+	"transition", "transition-property", "transition-duration", "transition-timing-function", "transition-delay",
+	"animation", "animation-name", "animation-duration", "animation-timing-function",
+	"animation-iteration-count", "animation-direction", "animation-play-state",
+	"animation-delay", "animation-fill-mode",
+}
+
+// https://docs.gtk.org/gtk3/css-properties.html
+// https://docs.gtk.org/gtk4/css-properties.html
+var gtkProperties = map[uint][]string{
+	3: slices.Concat(gtkCommonProperties, []string{
+		"-gtk-icon-effect", "icon-shadow", "-gtk-key-bindings", "engine",
+		"-gtk-outline-radius", "-gtk-outline-top-left-radius", "-gtk-outline-top-right-radius",
+		"-gtk-outline-bottom-right-radius", "-gtk-outline-bottom-left-radius",
+	}),
+	4: slices.Concat(gtkCommonProperties, []string{
+		"-gtk-icon-size", "-gtk-icon-filter", "-gtk-icon-weight",
+		"line-height", "text-transform", "filter", "transform", "transform-origin", "border-spacing",
+	}),
+}
+
+var gtkCommonPseudoClasses = []string{
+	"active", "hover", "selected", "disabled", "indeterminate", "checked", "focus", "backdrop",
+	"dir", "link", "visited", "drop", "not",
+	"first-child", "last-child", "only-child", "nth-child", "nth-last-child",
+}
+
+var gtkPseudoClasses = map[uint][]string{
+	3: slices.Concat(gtkCommonPseudoClasses, []string{"prelight", "insensitive", "inconsistent"}),
+	4: slices.Concat(gtkCommonPseudoClasses, []string{"focus-visible", "focus-within"}),
+}
+
+// gtk3 style properties of widget classes e.g "-GtkScrollbar-min-slider-length"
+var gtkStyleProperty = regexp.MustCompile(`^-[A-Z]`)
+
+var cssProperty = regexp.MustCompile(`^-{0,2}[_a-zA-Z][_a-zA-Z0-9-]*`)
+
+type cssIssue struct {
+	Offset int
+	Length int
+	Msg    string
+}
+
+// index after the whitespace and comments starting at i
+func cssSkipBlank(css string, i int) int {
+	for i < len(css) {
+		switch {
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end == -1 {
+				return len(css)
+			}
+			i += end + 4
+		case strings.ContainsRune(" \t\r\n", rune(css[i])):
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func (c gtkCss) issues(css string) []cssIssue {
+	var issues []cssIssue
+
+	var rules func(start, end int, keyframes bool)
+	rules = func(start, end int, keyframes bool) {
+		for pos := start; pos < end; {
+			i := cssIndexAny(css[pos:end], "{;}")
+			if i == -1 {
+				return
+			}
+			i += pos
+
+			if css[i] != '{' {
+				pos = i + 1
+				continue
+			}
+
+			close := pos + cssMatchingBrace(css[pos:end], i-pos)
+			prelude := strings.TrimSpace(css[pos:i])
+
+			switch {
+			case strings.HasPrefix(prelude, "@keyframes"):
+				rules(i+1, close, true)
+			case strings.HasPrefix(prelude, "@media"), strings.HasPrefix(prelude, "@supports"):
+				rules(i+1, close, keyframes)
+			case strings.HasPrefix(prelude, "@"):
+				// e.g gtk3 @binding-set, which has no declarations
+			default:
+				if !keyframes {
+					issues = append(issues, c.selectorIssues(css[pos:i], pos)...)
+				}
+				issues = append(issues, c.declarationIssues(css, i+1, close)...)
+			}
+
+			pos = close + 1
+		}
+	}
+
+	rules(0, len(css), false)
+	return issues
+}
+
+func (c gtkCss) selectorIssues(selector string, offset int) []cssIssue {
+	var issues []cssIssue
+	for i := 0; i < len(selector); i++ {
+		switch ch := selector[i]; {
+		case ch == '"' || ch == '\'':
+			i = cssStringEnd(selector, i) - 1
+		case strings.HasPrefix(selector[i:], "/*"):
+			i = cssSkipBlank(selector, i) - 1
+		case ch == '[':
+			if end := strings.IndexByte(selector[i:], ']'); end != -1 {
+				i += end
+			}
+		case strings.HasPrefix(selector[i:], "::"):
+			// pseudo-elements are matched as node names by gtk
+			i += 1 + len(cssIdent.FindString(selector[i+2:]))
+		case ch == ':':
+			name := cssIdent.FindString(selector[i+1:])
+			if name != "" && !slices.Contains(gtkPseudoClasses[c.Version], strings.ToLower(name)) {
+				issues = append(issues, cssIssue{offset + i, len(name) + 1,
+					fmt.Sprintf("Gtk%d does not support the :%s pseudo-class", c.Version, name)})
+			}
+			i += len(name)
+		}
+	}
+	return issues
+}
+
+func (c gtkCss) declarationIssues(css string, start, end int) []cssIssue {
+	var issues []cssIssue
+	for pos := start; pos < end; {
+		pos = cssSkipBlank(css[:end], pos)
+		if pos >= end {
+			break
+		}
+
+		next := end
+		if i := cssIndexAny(css[pos:end], ";{"); i != -1 {
+			next = pos + i
+			// nested rules are not valid gtk css, but do not report their selectors as properties
+			if css[next] == '{' {
+				pos = pos + cssMatchingBrace(css[pos:end], i) + 1
+				continue
+			}
+		}
+
+		name := cssProperty.FindString(css[pos:next])
+		if name != "" && !c.supportsProperty(name) {
+			issues = append(issues, cssIssue{pos, len(name),
+				fmt.Sprintf(`Gtk%d does not support the "%s" property`, c.Version, name)})
+		}
+
+		pos = next + 1
+	}
+	return issues
+}
+
+func (c gtkCss) supportsProperty(name string) bool {
+	switch {
+	case strings.HasPrefix(name, "--"):
+		return c.Version >= 4
+	case c.Version == 3 && gtkStyleProperty.MatchString(name):
+		return true
+	default:
+		return slices.Contains(gtkProperties[c.Version], strings.ToLower(name))
+	}
+}
+
+// 1-based line and 0-based column of an offset
+func cssPosition(css string, offset int) (int, int) {
+	before := css[:offset]
+	line := strings.Count(before, "\n") + 1
+	return line, offset - strings.LastIndexByte(before, '\n') - 1
+}
+
+func lineText(content string, line int) string {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// messages reports the css of file which gtk does not support, when the css
+// was compiled from file the source map is used to point at the original source
+func (c gtkCss) messages(css, file string, sourcemap *SourceMap) (warnings []api.Message, errors []api.Message) {
+	if c.Version == 0 {
+		return nil, nil
+	}
+
+	// nil for sources which can not be read
+	sources := map[string][]byte{}
+	for _, issue := range c.issues(css) {
+		line, col := cssPosition(css, issue.Offset)
+		loc := &api.Location{File: file, Line: line, Column: col, Length: issue.Length, LineText: lineText(css, line)}
+
+		if sourcemap != nil {
+			loc = &api.Location{File: file}
+			source, srcLine, srcCol, ok := sourcemap.Lookup(line, col+1)
+			if ok {
+				if u, err := url.Parse(source); err == nil && u.Scheme == "file" {
+					source = u.Path
+				}
+				if _, read := sources[source]; !read {
+					sources[source], _ = os.ReadFile(source)
+				}
+			}
+			// sources which are not on disk, e.g. of sass importers, are reported at file
+			if ok && sources[source] != nil {
+				text := lineText(string(sources[source]), srcLine)
+				loc = &api.Location{File: source, Line: srcLine, Column: srcCol - 1, LineText: text}
+
+				// sass maps whole selectors, so the issue is not always where the mapping points
+				if strings.HasPrefix(text[min(srcCol-1, len(text)):], css[issue.Offset:issue.Offset+issue.Length]) {
+					loc.Length = issue.Length
+				}
+			}
+		}
+
+		msg := api.Message{Text: issue.Msg, Location: loc}
+		if c.Strict {
+			errors = append(errors, msg)
+		} else {
+			warnings = append(warnings, msg)
+		}
+	}
+
+	return warnings, errors
+}
+
+var cssSourceMapComment = regexp.MustCompile(`\n?/\*# sourceMappingURL=data:([^,]*),(.*?)\s*\*/\s*$`)
+
+// splits the source map embedded by "sass --embed-source-map" from the css
+func splitCssSourceMap(css string) (string, *SourceMap) {
+	m := cssSourceMapComment.FindStringSubmatchIndex(css)
+	if m == nil {
+		return css, nil
+	}
+
+	meta, data := css[m[2]:m[3]], css[m[4]:m[5]]
+	var decoded []byte
+	var err error
+	if strings.HasSuffix(meta, ";base64") {
+		decoded, err = base64.StdEncoding.DecodeString(data)
+	} else {
+		var s string
+		s, err = url.PathUnescape(data)
+		decoded = []byte(s)
+	}
+
+	css = strings.TrimSpace(css[:m[0]])
+	if err != nil {
+		return css, nil
+	}
+
+	sourcemap, err := ParseSourceMap(decoded)
+	if err != nil {
+		return css, nil
+	}
+	return css, sourcemap
+}
+
+// cssPlugin checks plain css files, which are otherwise loaded as text by esbuild
+func cssPlugin(check gtkCss) api.Plugin {
+	return api.Plugin{
+		Name: "css",
+		Setup: func(build api.PluginBuild) {
+			build.OnLoad(api.OnLoadOptions{Filter: `\.css$`, Namespace: "file"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					if check.Version == 0 {
+						return api.OnLoadResult{}, nil
+					}
+
+					data, err := os.ReadFile(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+
+					content := string(data)
+					warnings, errors := check.messages(content, args.Path, nil)
+					return api.OnLoadResult{
+						Contents: &content,
+						Loader:   api.LoaderText,
+						Warnings: warnings,
+						Errors:   errors,
+					}, nil
+				})
+		},
+	}
+}
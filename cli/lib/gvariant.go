@@ -0,0 +1,687 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// gvariant is a value with its type, used for the "v" type
+// https://docs.gtk.org/glib/gvariant-format-strings.html
+type gvariant struct {
+	Type  string
+	Value any
+}
+
+// values are represented as
+//   - bool for "b"
+//   - int64 for "y", "n", "q", "i", "u", "h", "x" and "t"
+//   - float64 for "d"
+//   - string for "s", "o" and "g"
+//   - gvariant for "v"
+//   - nil or the value for "m"
+//   - []any for arrays, tuples and dict entries
+
+// splits the first complete type off of t
+func gvTypeNext(t string) (string, string) {
+	if t == "" {
+		return "", ""
+	}
+
+	switch t[0] {
+	case 'a', 'm':
+		first, rest := gvTypeNext(t[1:])
+		return t[:1] + first, rest
+	case '(', '{':
+		depth := 0
+		for i := 0; i < len(t); i++ {
+			switch t[i] {
+			case '(', '{':
+				depth++
+			case ')', '}':
+				if depth--; depth == 0 {
+					return t[:i+1], t[i+1:]
+				}
+			}
+		}
+		return t, ""
+	default:
+		return t[:1], t[1:]
+	}
+}
+
+// members of a tuple or dict entry type
+func gvTypeMembers(t string) []string {
+	var members []string
+	for rest := t[1 : len(t)-1]; rest != ""; {
+		var member string
+		member, rest = gvTypeNext(rest)
+		members = append(members, member)
+	}
+	return members
+}
+
+func gvTypeValid(t string) bool {
+	first, rest := gvTypeNext(t)
+	if first == "" || rest != "" {
+		return false
+	}
+
+	switch first[0] {
+	case 'b', 'y', 'n', 'q', 'i', 'u', 'h', 'x', 't', 'd', 's', 'o', 'g', 'v':
+		return len(first) == 1
+	case 'a', 'm':
+		return gvTypeValid(first[1:])
+	case '(':
+		if first[len(first)-1] != ')' {
+			return false
+		}
+		for _, m := range gvTypeMembers(first) {
+			if !gvTypeValid(m) {
+				return false
+			}
+		}
+		return true
+	case '{':
+		if first[len(first)-1] != '}' {
+			return false
+		}
+		members := gvTypeMembers(first)
+		return len(members) == 2 &&
+			strings.ContainsRune("bynqiuhxtdsog", rune(members[0][0])) && len(members[0]) == 1 &&
+			gvTypeValid(members[1])
+	}
+	return false
+}
+
+// alignment and fixed size of a type, the size is 0 for variable sized types
+func gvTypeInfo(t string) (int, int) {
+	switch t[0] {
+	case 'b', 'y':
+		return 1, 1
+	case 'n', 'q':
+		return 2, 2
+	case 'i', 'u', 'h':
+		return 4, 4
+	case 'x', 't', 'd':
+		return 8, 8
+	case 's', 'o', 'g':
+		return 1, 0
+	case 'v':
+		return 8, 0
+	case 'a', 'm':
+		align, _ := gvTypeInfo(t[1:])
+		return align, 0
+	default:
+		align, size, fixed := 1, 0, true
+		for _, m := range gvTypeMembers(t) {
+			a, s := gvTypeInfo(m)
+			align = max(align, a)
+			size = gvAlign(size, a) + s
+			fixed = fixed && s != 0
+		}
+		if !fixed {
+			return align, 0
+		}
+		if size == 0 {
+			return align, 1
+		}
+		return align, gvAlign(size, align)
+	}
+}
+
+func gvAlign(n, align int) int {
+	return (n + align - 1) &^ (align - 1)
+}
+
+func gvOffsetSize(size int) int {
+	switch {
+	case size > math.MaxUint32:
+		return 8
+	case size > math.MaxUint16:
+		return 4
+	case size > math.MaxUint8:
+		return 2
+	case size > 0:
+		return 1
+	}
+	return 0
+}
+
+// appends the framing offsets of a container
+func gvFrame(body []byte, offsets []int) []byte {
+	if len(offsets) == 0 {
+		return body
+	}
+
+	size := len(body)
+	for _, n := range []int{1, 2, 4, 8} {
+		size = len(body) + n*len(offsets)
+		if gvOffsetSize(size) <= n {
+			break
+		}
+	}
+
+	n := gvOffsetSize(size)
+	for _, offset := range offsets {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(offset))
+		body = append(body, buf[:n]...)
+	}
+	return body
+}
+
+func gvPad(b []byte, align int) []byte {
+	for len(b)%align != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// gvSerialize serializes a value in normal form, little endian
+func gvSerialize(t string, value any) []byte {
+	switch t[0] {
+	case 'b':
+		if value.(bool) {
+			return []byte{1}
+		}
+		return []byte{0}
+	case 'y':
+		return []byte{byte(value.(int64))}
+	case 'n', 'q':
+		return binary.LittleEndian.AppendUint16(nil, uint16(value.(int64)))
+	case 'i', 'u', 'h':
+		return binary.LittleEndian.AppendUint32(nil, uint32(value.(int64)))
+	case 'x', 't':
+		return binary.LittleEndian.AppendUint64(nil, uint64(value.(int64)))
+	case 'd':
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(value.(float64)))
+	case 's', 'o', 'g':
+		return append([]byte(value.(string)), 0)
+	case 'v':
+		v := value.(gvariant)
+		data := append(gvSerialize(v.Type, v.Value), 0)
+		return append(data, v.Type...)
+	case 'm':
+		if value == nil {
+			return nil
+		}
+		data := gvSerialize(t[1:], value)
+		if _, fixed := gvTypeInfo(t[1:]); fixed == 0 {
+			data = append(data, 0)
+		}
+		return data
+	case 'a':
+		elem := t[1:]
+		align, fixed := gvTypeInfo(elem)
+		var body []byte
+		var offsets []int
+		for _, v := range value.([]any) {
+			body = append(gvPad(body, align), gvSerialize(elem, v)...)
+			offsets = append(offsets, len(body))
+		}
+		if fixed != 0 {
+			return body
+		}
+		return gvFrame(body, offsets)
+	default:
+		members := gvTypeMembers(t)
+		values := value.([]any)
+		var body []byte
+		var offsets []int
+		for i, m := range members {
+			align, fixed := gvTypeInfo(m)
+			body = append(gvPad(body, align), gvSerialize(m, values[i])...)
+			if fixed == 0 && i < len(members)-1 {
+				offsets = append(offsets, len(body))
+			}
+		}
+
+		if align, fixed := gvTypeInfo(t); fixed != 0 {
+			if len(body) == 0 {
+				return []byte{0}
+			}
+			return gvPad(body, align)
+		}
+
+		// the offsets of the members are stored in reverse order
+		for i, j := 0, len(offsets)-1; i < j; i, j = i+1, j-1 {
+			offsets[i], offsets[j] = offsets[j], offsets[i]
+		}
+		return gvFrame(body, offsets)
+	}
+}
+
+// gvParser parses the GVariant text format of a value of a known type
+// https://docs.gtk.org/glib/gvariant-text-format.html
+type gvParser struct {
+	src string
+	pos int
+}
+
+var gvKeywords = []string{
+	"boolean", "byte", "int16", "uint16", "int32", "uint32", "handle",
+	"int64", "uint64", "double", "string", "objectpath", "signature",
+}
+
+// gvParse parses the text of a value of type t
+func gvParse(t, text string) (value any, err error) {
+	p := &gvParser{src: text}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(gvError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	value = p.value(t)
+	p.space()
+	if p.pos < len(p.src) {
+		p.fail("unexpected %q", p.src[p.pos:])
+	}
+	return value, nil
+}
+
+type gvError string
+
+func (e gvError) Error() string {
+	return string(e)
+}
+
+func (p *gvParser) fail(format string, args ...any) {
+	panic(gvError(fmt.Sprintf(format, args...)))
+}
+
+func (p *gvParser) space() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *gvParser) peek() byte {
+	p.space()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *gvParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail("expected %q", c)
+	}
+	p.pos++
+}
+
+func (p *gvParser) word() string {
+	p.space()
+	start := p.pos
+	for p.pos < len(p.src) && strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_+-.", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// skips a type annotation or keyword, which have to agree with t
+func (p *gvParser) annotation(t string) {
+	if p.peek() == '@' {
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		if p.src[start:p.pos] != t {
+			p.fail("type %s does not match %s", p.src[start:p.pos], t)
+		}
+		return
+	}
+
+	save := p.pos
+	if w := p.word(); slices.Contains(gvKeywords, w) {
+		return
+	}
+	p.pos = save
+}
+
+var gvIntRanges = map[byte][2]float64{
+	'y': {0, math.MaxUint8},
+	'n': {math.MinInt16, math.MaxInt16},
+	'q': {0, math.MaxUint16},
+	'i': {math.MinInt32, math.MaxInt32},
+	'u': {0, math.MaxUint32},
+	'h': {math.MinInt32, math.MaxInt32},
+	'x': {math.MinInt64, math.MaxInt64},
+	't': {0, math.MaxUint64},
+}
+
+func (p *gvParser) value(t string) any {
+	p.annotation(t)
+
+	switch c := t[0]; c {
+	case 'b':
+		switch p.word() {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		p.fail("expected a boolean")
+	case 'y', 'n', 'q', 'i', 'u', 'h', 'x', 't':
+		if c == 'y' && (p.peek() == '\'' || p.peek() == '"') {
+			s := p.string()
+			if len(s) != 1 {
+				p.fail("expected a single byte")
+			}
+			return int64(s[0])
+		}
+		word := p.word()
+		if c == 't' {
+			n, err := strconv.ParseUint(word, 0, 64)
+			if err != nil {
+				p.fail("invalid number %s", word)
+			}
+			return int64(n)
+		}
+		n, err := strconv.ParseInt(word, 0, 64)
+		r := gvIntRanges[c]
+		if err != nil || float64(n) < r[0] || float64(n) > r[1] {
+			p.fail("invalid number %s for type %c", word, c)
+		}
+		return n
+	case 'd':
+		word := p.word()
+		f, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			p.fail("invalid number %s", word)
+		}
+		return f
+	case 's', 'o', 'g':
+		return p.string()
+	case 'v':
+		p.expect('<')
+		v := p.variant()
+		p.expect('>')
+		return v
+	case 'm':
+		save := p.pos
+		switch p.word() {
+		case "nothing":
+			return nil
+		case "just":
+		default:
+			p.pos = save
+		}
+		return p.value(t[1:])
+	case 'a':
+		if t == "ay" && p.peek() == 'b' {
+			p.pos++
+			var bytes []any
+			for _, b := range []byte(p.string()) {
+				bytes = append(bytes, int64(b))
+			}
+			return bytes
+		}
+		if t[1] == '{' && p.peek() == '{' {
+			return p.dict(t[1:])
+		}
+		return p.list(t[1:])
+	case '(':
+		return p.tuple(t)
+	case '{':
+		members := gvTypeMembers(t)
+		p.expect('{')
+		k := p.value(members[0])
+		p.expect(',')
+		v := p.value(members[1])
+		p.expect('}')
+		return []any{k, v}
+	}
+
+	p.fail("unsupported type %s", t)
+	return nil
+}
+
+func (p *gvParser) list(elem string) []any {
+	p.expect('[')
+	values := []any{}
+	if p.peek() == ']' {
+		p.pos++
+		return values
+	}
+	for {
+		values = append(values, p.value(elem))
+		if p.peek() == ']' {
+			p.pos++
+			return values
+		}
+		p.expect(',')
+	}
+}
+
+// {k: v, ...} syntax of an array of dict entries
+func (p *gvParser) dict(entry string) []any {
+	members := gvTypeMembers(entry)
+	p.expect('{')
+	values := []any{}
+	if p.peek() == '}' {
+		p.pos++
+		return values
+	}
+	for {
+		k := p.value(members[0])
+		p.expect(':')
+		v := p.value(members[1])
+		values = append(values, []any{k, v})
+		if p.peek() == '}' {
+			p.pos++
+			return values
+		}
+		p.expect(',')
+	}
+}
+
+func (p *gvParser) tuple(t string) []any {
+	members := gvTypeMembers(t)
+	p.expect('(')
+	values := []any{}
+	for i, m := range members {
+		values = append(values, p.value(m))
+		if i < len(members)-1 {
+			p.expect(',')
+		} else if p.peek() == ',' {
+			p.pos++
+		}
+	}
+	p.expect(')')
+	return values
+}
+
+// a value of a variant, which has to be inferred if it is not annotated
+func (p *gvParser) variant() gvariant {
+	if p.peek() == '@' {
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		t := p.src[start:p.pos]
+		if !gvTypeValid(t) {
+			p.fail("invalid type %s", t)
+		}
+		return gvariant{t, p.value(t)}
+	}
+
+	t := p.infer()
+	return gvariant{t, p.value(t)}
+}
+
+// the type of the upcoming value, only simple values and arrays of them are inferred
+func (p *gvParser) infer() string {
+	save := p.pos
+	defer func() { p.pos = save }()
+
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		return "s"
+	case c == '<':
+		return "v"
+	case c == '[':
+		p.pos++
+		if p.peek() == ']' {
+			p.fail("can not infer the type of an empty array")
+		}
+		return "a" + p.infer()
+	case c == '(':
+		p.pos++
+		t := "("
+		for p.peek() != ')' {
+			member := p.infer()
+			p.value(member)
+			t += member
+			if p.peek() == ',' {
+				p.pos++
+			}
+		}
+		return t + ")"
+	}
+
+	word := p.word()
+	switch {
+	case word == "true" || word == "false":
+		return "b"
+	case strings.ContainsAny(word, ".eEn") && !strings.HasPrefix(word, "0x"):
+		return "d"
+	case word != "":
+		return "i"
+	}
+	p.fail("can not infer the type of the value")
+	return ""
+}
+
+func (p *gvParser) string() string {
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		p.fail("expected a string")
+	}
+	p.pos++
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			p.fail("unterminated string")
+		}
+
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return b.String()
+		case '\\':
+			if p.pos >= len(p.src) {
+				p.fail("unterminated string")
+			}
+			e := p.src[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case 'a':
+				b.WriteByte('\a')
+			case 'u', 'U':
+				size := 4
+				if e == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.src) {
+					p.fail("invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					p.fail("invalid unicode escape")
+				}
+				b.WriteRune(rune(code))
+				p.pos += size
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+var errGvCompare = errors.New("values can not be compared")
+
+// compares two numbers of the same type
+func gvCompare(t string, a, b any) (int, error) {
+	switch t {
+	case "t":
+		return compareOrdered(uint64(a.(int64)), uint64(b.(int64))), nil
+	case "y", "n", "q", "i", "u", "h", "x":
+		return compareOrdered(a.(int64), b.(int64)), nil
+	case "d":
+		return compareOrdered(a.(float64), b.(float64)), nil
+	}
+	return 0, errGvCompare
+}
+
+func compareOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// TypeScript type of the unpacked value of a type
+func gvTsType(t string) string {
+	switch t[0] {
+	case 'b':
+		return "boolean"
+	case 'y', 'n', 'q', 'i', 'u', 'h', 'x', 't', 'd':
+		return "number"
+	case 's', 'o', 'g':
+		return "string"
+	case 'v':
+		return "unknown"
+	case 'm':
+		return gvTsType(t[1:]) + " | null"
+	case 'a':
+		if t[1] == '{' {
+			members := gvTypeMembers(t[1:])
+			key := gvTsType(members[0])
+			return fmt.Sprintf("Record<%s, %s>", key, gvTsType(members[1]))
+		}
+		elem := gvTsType(t[1:])
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	default:
+		var members []string
+		for _, m := range gvTypeMembers(t) {
+			members = append(members, gvTsType(m))
+		}
+		return "[" + strings.Join(members, ", ") + "]"
+	}
+}
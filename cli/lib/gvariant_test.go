@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGvTypeValid(t *testing.T) {
+	tests := map[string]bool{
+		"s":      true,
+		"as":     true,
+		"a{sv}":  true,
+		"m(ib)":  true,
+		"()":     true,
+		"aa{yd}": true,
+		"":       false,
+		"a":      false,
+		"m":      false,
+		"ss":     false,
+		"z":      false,
+		"(":      false,
+		"(s":     false,
+		"{":      false,
+		"a{":     false,
+		"m{":     false,
+		"{}":     false,
+		"{s}":    false,
+		"{s)":    false,
+		"(s}":    false,
+		"{sss}":  false,
+		"{vs}":   false,
+		"{asb}":  false,
+	}
+
+	for typ, want := range tests {
+		if got := gvTypeValid(typ); got != want {
+			t.Errorf("gvTypeValid(%q) = %v, want %v", typ, got, want)
+		}
+	}
+}
+
+func TestGvParse(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  string
+		text string
+		want string // JSON of the value
+		err  string // substring of the error
+	}{
+		{name: "int", typ: "i", text: "42", want: `42`},
+		{name: "keyword", typ: "i", text: "int32 -5", want: `-5`},
+		{name: "byte char", typ: "y", text: "'a'", want: `97`},
+		{name: "double", typ: "d", text: "1.5", want: `1.5`},
+		{name: "escapes", typ: "s", text: `"a\né"`, want: `"a\né"`},
+		{name: "nothing", typ: "ms", text: "nothing", want: `null`},
+		{name: "just", typ: "ms", text: "just 'x'", want: `"x"`},
+		{name: "maybe without just", typ: "ms", text: "'x'", want: `"x"`},
+		{name: "bytestring", typ: "ay", text: "b'hi'", want: `[104,105]`},
+		{name: "list", typ: "as", text: "['a', 'b']", want: `["a","b"]`},
+		{name: "dict", typ: "a{sb}", text: "{'a': true}", want: `[["a",true]]`},
+		{name: "dict entry", typ: "{sb}", text: "{'a', false}", want: `["a",false]`},
+		{name: "tuple", typ: "(sd)", text: "('x', 1.5,)", want: `["x",1.5]`},
+		{name: "inferred variant", typ: "v", text: "<[1, 2]>", want: `{"Type":"ai","Value":[1,2]}`},
+		{name: "annotated variant", typ: "v", text: "<@u 1>", want: `{"Type":"u","Value":1}`},
+
+		{name: "out of range", typ: "y", text: "300", err: "invalid number 300"},
+		{name: "mismatched annotation", typ: "as", text: "@ai []", err: "type ai does not match as"},
+		{name: "unterminated string", typ: "s", text: "'x", err: "unterminated string"},
+		{name: "trailing", typ: "i", text: "1 2", err: "unexpected"},
+		{name: "missing brace", typ: "a{sb}", text: "{'a': true", err: "expected ','"},
+		{name: "malformed dict entry type", typ: "v", text: "<@{ 1>", err: "invalid type {"},
+		{name: "malformed array type", typ: "v", text: "<@a{ []>", err: "invalid type a{"},
+		{name: "malformed maybe type", typ: "v", text: "<@m{ nothing>", err: "invalid type m{"},
+		{name: "empty array", typ: "v", text: "<[]>", err: "can not infer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := gvParse(test.typ, test.text)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			got, _ := json.Marshal(value)
+			if string(got) != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGvSerialize(t *testing.T) {
+	tests := []struct {
+		typ  string
+		text string
+		want string
+	}{
+		{"b", "true", "\x01"},
+		{"i", "-1", "\xff\xff\xff\xff"},
+		{"q", "258", "\x02\x01"},
+		{"s", "'hi'", "hi\x00"},
+		{"ms", "nothing", ""},
+		{"ms", "'x'", "x\x00\x00"},
+		{"mi", "1", "\x01\x00\x00\x00"},
+		{"ai", "[1, 2]", "\x01\x00\x00\x00\x02\x00\x00\x00"},
+		{"as", "['a', 'bc']", "a\x00bc\x00\x02\x05"},
+		{"(si)", "('a', 1)", "a\x00\x00\x00\x01\x00\x00\x00\x02"},
+		{"(yi)", "(1, 2)", "\x01\x00\x00\x00\x02\x00\x00\x00"},
+		{"a{sb}", "{'a': true}", "a\x00\x01\x02\x04"},
+		{"v", "<@i 1>", "\x01\x00\x00\x00\x00i"},
+		{"()", "()", "\x00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.typ+" "+test.text, func(t *testing.T) {
+			value, err := gvParse(test.typ, test.text)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := string(gvSerialize(test.typ, value)); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseGSchemasInvalidType(t *testing.T) {
+	for _, typ := range []string{"{", "a{", "m{", "{s)"} {
+		t.Run(typ, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "test.gschema.xml")
+			schema := `<schemalist><schema id="org.example" path="/org/example/">` +
+				`<key name="k" type="` + typ + `"><default>nothing</default></key>` +
+				`</schema></schemalist>`
+			if err := os.WriteFile(file, []byte(schema), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := ParseGSchemas([]string{file})
+			var schemaErr *GSchemaError
+			if !errors.As(err, &schemaErr) || !strings.Contains(schemaErr.Msg, `invalid type "`+typ+`"`) {
+				t.Fatalf("got error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+)
+
+// gvdbItem is an entry of a GVDB hash table, it holds either a value,
+// a nested table or the list of its children
+type gvdbItem struct {
+	key      string
+	parent   *gvdbItem
+	children []*gvdbItem
+	value    []byte
+	table    *gvdbTable
+	index    uint32
+}
+
+// gvdbTable is a hash table of a GVDB file, the format gschemas.compiled is written in
+// https://gitlab.gnome.org/GNOME/gvdb/-/blob/main/gvdb/gvdb-format.h
+type gvdbTable struct {
+	items []*gvdbItem
+}
+
+func (t *gvdbTable) insert(key string) *gvdbItem {
+	item := &gvdbItem{key: key}
+	t.items = append(t.items, item)
+	return item
+}
+
+// inserts a value which is wrapped into a variant
+func (t *gvdbTable) insertValue(key, typ string, value any) *gvdbItem {
+	item := t.insert(key)
+	item.value = gvSerialize("v", gvariant{typ, value})
+	return item
+}
+
+func (t *gvdbTable) insertTable(key string) *gvdbTable {
+	item := t.insert(key)
+	item.table = &gvdbTable{}
+	return item.table
+}
+
+// the key of an item has to start with the key of its parent,
+// parents list their children sorted by key
+func (item *gvdbItem) setParent(parent *gvdbItem) {
+	item.parent = parent
+	i, _ := slices.BinarySearchFunc(parent.children, item.key, func(c *gvdbItem, key string) int {
+		return strings.Compare(c.key, key)
+	})
+	parent.children = slices.Insert(parent.children, i, item)
+}
+
+func gvdbHash(key string) uint32 {
+	hash := uint32(5381)
+	for i := 0; i < len(key); i++ {
+		hash = hash*33 + uint32(int32(int8(key[i])))
+	}
+	return hash
+}
+
+type gvdbWriter struct {
+	out []byte
+}
+
+// reserves size bytes aligned to align and returns their offset
+func (w *gvdbWriter) allocate(align, size int) int {
+	for len(w.out)%align != 0 {
+		w.out = append(w.out, 0)
+	}
+	start := len(w.out)
+	w.out = append(w.out, make([]byte, size)...)
+	return start
+}
+
+func (w *gvdbWriter) pointer(at, start, end int) {
+	binary.LittleEndian.PutUint32(w.out[at:], uint32(start))
+	binary.LittleEndian.PutUint32(w.out[at+4:], uint32(end))
+}
+
+func (w *gvdbWriter) hash(table *gvdbTable) (int, int) {
+	n := len(table.items)
+	buckets := make([][]*gvdbItem, n)
+	for _, item := range table.items {
+		b := gvdbHash(item.key) % uint32(n)
+		buckets[b] = append(buckets[b], item)
+	}
+
+	index := uint32(0)
+	for _, bucket := range buckets {
+		for _, item := range bucket {
+			item.index = index
+			index++
+		}
+	}
+
+	const headerSize, itemSize = 8, 24
+	start := w.allocate(4, headerSize+4*n+itemSize*n)
+	end := len(w.out)
+
+	binary.LittleEndian.PutUint32(w.out[start:], 0) // bloom filter words
+	binary.LittleEndian.PutUint32(w.out[start+4:], uint32(n))
+
+	itemAt := start + headerSize + 4*n
+	for b, bucket := range buckets {
+		first := uint32(itemAt-start-headerSize-4*n) / itemSize
+		binary.LittleEndian.PutUint32(w.out[start+headerSize+4*b:], first)
+
+		for _, item := range bucket {
+			parent, key := uint32(0xffffffff), item.key
+			if item.parent != nil {
+				parent, key = item.parent.index, strings.TrimPrefix(item.key, item.parent.key)
+			}
+
+			binary.LittleEndian.PutUint32(w.out[itemAt:], gvdbHash(item.key))
+			binary.LittleEndian.PutUint32(w.out[itemAt+4:], parent)
+
+			keyStart := w.allocate(1, len(key))
+			copy(w.out[keyStart:], key)
+			binary.LittleEndian.PutUint32(w.out[itemAt+8:], uint32(keyStart))
+			binary.LittleEndian.PutUint16(w.out[itemAt+12:], uint16(len(key)))
+
+			switch {
+			case item.value != nil:
+				w.out[itemAt+14] = 'v'
+				at := w.allocate(8, len(item.value))
+				copy(w.out[at:], item.value)
+				w.pointer(itemAt+16, at, at+len(item.value))
+			case item.table != nil:
+				w.out[itemAt+14] = 'H'
+				s, e := w.hash(item.table)
+				w.pointer(itemAt+16, s, e)
+			default:
+				w.out[itemAt+14] = 'L'
+				at := w.allocate(4, 4*len(item.children))
+				for i, child := range item.children {
+					binary.LittleEndian.PutUint32(w.out[at+4*i:], child.index)
+				}
+				w.pointer(itemAt+16, at, at+4*len(item.children))
+			}
+
+			itemAt += itemSize
+		}
+	}
+
+	return start, end
+}
+
+// gvdbWrite serializes a root table into a GVDB file
+func gvdbWrite(root *gvdbTable) []byte {
+	w := &gvdbWriter{out: make([]byte, 24)}
+	copy(w.out, "GVariant")
+	// version and options are 0
+	start, end := w.hash(root)
+	w.pointer(16, start, end)
+	return w.out
+}
@@ -0,0 +1,387 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// gettext functions and the 1-based position of their arguments, 0 when missing
+var gettextKeywords = map[string]struct{ Context, Msgid, Plural int }{
+	"_":         {0, 1, 0},
+	"N_":        {0, 1, 0},
+	"gettext":   {0, 1, 0},
+	"ngettext":  {0, 1, 2},
+	"C_":        {1, 2, 0},
+	"NC_":       {1, 2, 0},
+	"pgettext":  {1, 2, 0},
+	"npgettext": {1, 2, 3},
+}
+
+// comments starting with this tag are extracted for translators
+const translatorsTag = "TRANSLATORS"
+
+type jsToken struct {
+	Kind byte // 'i' identifier, 's' string, 'p' punctuation
+	Text string
+	Line int
+}
+
+// tokens after which a slash starts a regular expression
+var jsRegexPrefix = []string{
+	"return", "typeof", "instanceof", "in", "of", "new", "delete", "void",
+	"throw", "case", "do", "else", "yield", "await",
+}
+
+// jsTokens is a minimal lexer which is only good enough to find the string arguments of calls,
+// comments which start with TRANSLATORS are returned keyed by the line they end on
+func jsTokens(source string) ([]jsToken, map[int]string) {
+	var tokens []jsToken
+	comments := map[int]string{}
+	line := 1
+
+	regexAllowed := func() bool {
+		if len(tokens) == 0 {
+			return true
+		}
+		last := tokens[len(tokens)-1]
+		switch last.Kind {
+		case 's':
+			return false
+		case 'i':
+			return slices.Contains(jsRegexPrefix, last.Text)
+		}
+		return !strings.Contains(")]}", last.Text)
+	}
+
+	comment := func(text string) {
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, translatorsTag) {
+			comments[line] = text
+		}
+	}
+
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case strings.HasPrefix(source[i:], "//"):
+			end := strings.IndexByte(source[i:], '\n')
+			if end == -1 {
+				end = len(source) - i
+			}
+			comment(source[i+2 : i+end])
+			i += end
+
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end == -1 {
+				end = len(source) - i - 2
+			}
+			text := source[i+2 : i+2+end]
+			line += strings.Count(text, "\n")
+			lines := strings.Split(text, "\n")
+			for j := range lines {
+				lines[j] = strings.TrimLeft(strings.TrimSpace(lines[j]), "* ")
+			}
+			comment(strings.Join(lines, " "))
+			i += end + 4
+
+		case c == '"' || c == '\'':
+			s, n, ok := jsUnquote(source[i:], c)
+			if !ok {
+				// most likely an apostrophe in JSX text
+				i++
+				continue
+			}
+			tokens = append(tokens, jsToken{'s', s, line})
+			i += n
+
+		case c == '`':
+			n, ok := jsTemplateEnd(source[i:])
+			text := source[i : i+n]
+			if ok && !strings.Contains(text, "${") {
+				if s, _, ok := jsUnquote(strings.ReplaceAll(text, "\n", `\n`), '`'); ok {
+					tokens = append(tokens, jsToken{'s', s, line})
+				}
+			} else {
+				tokens = append(tokens, jsToken{'p', "`", line})
+			}
+			line += strings.Count(text, "\n")
+			i += n
+
+		case c == '/' && regexAllowed():
+			j := i + 1
+			for class := false; ; j++ {
+				if j >= len(source) || source[j] == '\n' {
+					// not a regex after all, e.g a closing JSX tag
+					j = -1
+					break
+				}
+				if source[j] == '\\' {
+					j++
+				} else if source[j] == '[' {
+					class = true
+				} else if source[j] == ']' {
+					class = false
+				} else if source[j] == '/' && !class {
+					break
+				}
+			}
+			if j == -1 {
+				tokens = append(tokens, jsToken{'p', "/", line})
+				i++
+				continue
+			}
+			tokens = append(tokens, jsToken{'p', "/regex/", line})
+			i = j + 1
+
+		case c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf:
+			j := i + 1
+			for j < len(source) && (source[j] == '_' || source[j] == '$' || source[j] >= utf8.RuneSelf ||
+				source[j] >= 'a' && source[j] <= 'z' || source[j] >= 'A' && source[j] <= 'Z' || source[j] >= '0' && source[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, jsToken{'i', source[i:j], line})
+			i = j
+
+		case c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(source) && (source[j] == '.' || source[j] == '_' ||
+				source[j] >= '0' && source[j] <= '9' || source[j] >= 'a' && source[j] <= 'z' || source[j] >= 'A' && source[j] <= 'Z') {
+				j++
+			}
+			tokens = append(tokens, jsToken{'i', source[i:j], line})
+			i = j
+
+		default:
+			tokens = append(tokens, jsToken{'p', string(c), line})
+			i++
+		}
+	}
+
+	return tokens, comments
+}
+
+// end of a template literal including nested substitutions
+func jsTemplateEnd(s string) (int, bool) {
+	depth := 0
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case depth == 0 && s[i] == '`':
+			return i + 1, true
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case depth > 0 && s[i] == '}':
+			depth--
+		case depth > 0 && s[i] == '`':
+			n, _ := jsTemplateEnd(s[i:])
+			i += n - 1
+		}
+	}
+	return len(s), false
+}
+
+// decodes a quoted string, returning the length of the literal
+func jsUnquote(s string, quote byte) (string, int, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, true
+		case c == '\n':
+			return "", 0, false
+		case c != '\\' || i+1 == len(s):
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '0':
+			b.WriteByte(0)
+		case '\n':
+			// line continuation
+		case 'x':
+			if n, err := strconv.ParseUint(s[i+1:min(i+3, len(s))], 16, 8); err == nil {
+				b.WriteRune(rune(n))
+				i += 2
+			}
+		case 'u':
+			hex := s[i+1 : min(i+5, len(s))]
+			if strings.HasPrefix(s[i+1:], "{") {
+				hex, _, _ = strings.Cut(s[i+2:], "}")
+				i += 2
+			}
+			if n, err := strconv.ParseUint(hex, 16, 32); err == nil {
+				b.WriteRune(rune(n))
+				i += len(hex)
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, false
+}
+
+// the arguments of the call starting at the opening paren, an argument is
+// only a string if it is a literal or a concatenation of literals
+func jsCallArgs(tokens []jsToken) []*string {
+	var args []*string
+	var arg []jsToken
+	depth := 0
+
+	finish := func() {
+		value, ok := "", len(arg)%2 == 1
+		for i, t := range arg {
+			if i%2 == 0 {
+				ok = ok && t.Kind == 's'
+				value += t.Text
+			} else {
+				ok = ok && t.Text == "+"
+			}
+		}
+
+		if ok {
+			args = append(args, &value)
+		} else {
+			args = append(args, nil)
+		}
+		arg = nil
+	}
+
+	for _, t := range tokens[1:] {
+		if t.Kind == 'p' {
+			switch t.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				if depth == 0 {
+					finish()
+					return args
+				}
+				depth--
+			case ",":
+				if depth == 0 {
+					finish()
+					continue
+				}
+			}
+		}
+		arg = append(arg, t)
+	}
+	return nil
+}
+
+// ExtractMessages collects the messages of gettext calls in the source files
+// into a template, references are relative to root
+func ExtractMessages(root string, files []string, domain string) *Po {
+	header := fmt.Sprintf("Project-Id-Version: %s\n", domain) +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: text/plain; charset=UTF-8\n" +
+		"Content-Transfer-Encoding: 8bit\n"
+
+	pot := &Po{Entries: []*PoEntry{{
+		Comments: []string{"Translations of " + domain, "This file is generated by ags i18n extract"},
+		Flags:    []string{"fuzzy"},
+		Msgstr:   []string{header},
+	}}}
+
+	for _, file := range files {
+		tokens, comments := jsTokens(string(ReadFile(file)))
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			rel = file
+		}
+
+		for i, t := range tokens {
+			keyword, ok := gettextKeywords[t.Text]
+			if t.Kind != 'i' || !ok || i+1 == len(tokens) || tokens[i+1].Text != "(" {
+				continue
+			}
+
+			// declarations e.g "function _(msgid) {}"
+			if i > 0 && (tokens[i-1].Text == "function" || tokens[i-1].Text == "as") {
+				continue
+			}
+
+			args := jsCallArgs(tokens[i+1:])
+			arg := func(n int) (string, bool) {
+				if n == 0 || n > len(args) || args[n-1] == nil {
+					return "", false
+				}
+				return *args[n-1], true
+			}
+
+			msgid, ok := arg(keyword.Msgid)
+			if !ok || msgid == "" {
+				continue
+			}
+
+			entry := &PoEntry{Msgid: msgid, Msgstr: []string{""}}
+			if keyword.Context > 0 {
+				if entry.Context, ok = arg(keyword.Context); !ok {
+					continue
+				}
+				entry.HasContext = true
+			}
+			if keyword.Plural > 0 {
+				if entry.MsgidPlural, ok = arg(keyword.Plural); !ok {
+					continue
+				}
+				entry.Msgstr = []string{"", ""}
+			}
+
+			if existing := pot.find(entry.key()); existing != nil {
+				entry = existing
+			} else {
+				pot.Entries = append(pot.Entries, entry)
+			}
+
+			ref := fmt.Sprintf("%s:%d", filepath.ToSlash(rel), t.Line)
+			if !slices.Contains(entry.References, ref) {
+				entry.References = append(entry.References, ref)
+			}
+
+			for l := t.Line; l >= t.Line-1; l-- {
+				if c, ok := comments[l]; ok && !slices.Contains(entry.Extracted, c) {
+					entry.Extracted = append(entry.Extracted, c)
+					break
+				}
+			}
+
+			if strings.Contains(msgid, "%") || strings.Contains(entry.MsgidPlural, "%") {
+				if !slices.Contains(entry.Flags, "javascript-format") {
+					entry.Flags = append(entry.Flags, "javascript-format")
+				}
+			}
+		}
+	}
+
+	return pot
+}
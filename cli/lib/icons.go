@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// contexts of the freedesktop icon naming specification keyed by their directory
+// https://specifications.freedesktop.org/icon-naming-spec/latest/
+var iconContexts = map[string]string{
+	"actions":    "Actions",
+	"animations": "Animations",
+	"apps":       "Applications",
+	"categories": "Categories",
+	"devices":    "Devices",
+	"emblems":    "Emblems",
+	"emotes":     "Emotes",
+	"intl":       "International",
+	"mimetypes":  "MimeTypes",
+	"places":     "Places",
+	"status":     "Status",
+}
+
+var iconName = regexp.MustCompile(`^[\w.+-]+$`)
+
+// iconDir is a directory of an icon theme
+// https://specifications.freedesktop.org/icon-theme-spec/latest/
+type iconDir struct {
+	Path    string
+	Context string
+	Size    int
+	Scale   int
+	Type    string // Fixed, Scalable or Threshold
+	MinSize int
+	MaxSize int
+}
+
+// width and height of a png from its IHDR chunk
+func pngSize(data []byte) (int, int, bool) {
+	if len(data) < 24 || string(data[1:4]) != "PNG" || string(data[12:16]) != "IHDR" {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint32(data[16:])), int(binary.BigEndian.Uint32(data[20:])), true
+}
+
+// IconThemeError is an icon which can not be placed into the theme
+type IconThemeError struct {
+	File string
+	Msg  string
+}
+
+func (e *IconThemeError) Error() string {
+	return e.File + ": " + e.Msg
+}
+
+// BuildIconTheme arranges the svg and png icons of src into a hicolor theme in out,
+// png icons are placed by their size, "@2x" suffixes mark scaled icons, the context
+// is read from the name of a parent directory and defaults to apps. Icons in a
+// "symbolic" directory are named "-symbolic" so that gtk recolors them.
+// The directory names are the ones of the hicolor theme, as gtk only looks
+// into the directories listed by the first index.theme it finds.
+func BuildIconTheme(src, out string) ([]string, error) {
+	files := globFiles(src, []string{"*.svg", "*.png"})
+	theme := filepath.Join(out, "hicolor")
+
+	var written []string
+	dirs := map[string]iconDir{}
+	sources := map[string]string{}
+
+	for _, file := range files {
+		rel, _ := filepath.Rel(src, file)
+		parts := strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/")
+		ext := filepath.Ext(file)
+		name := strings.TrimSuffix(filepath.Base(file), ext)
+
+		context := "apps"
+		for _, part := range parts {
+			if _, ok := iconContexts[part]; ok {
+				context = part
+			}
+		}
+
+		symbolic := strings.HasSuffix(name, "-symbolic")
+		if slices.Contains(parts, "symbolic") && !symbolic {
+			name += "-symbolic"
+			symbolic = true
+		}
+
+		var dir iconDir
+		switch {
+		case ext == ".svg" && symbolic:
+			dir = iconDir{Path: "symbolic/" + context, Size: 16, Scale: 1, Type: "Scalable", MinSize: 8, MaxSize: 512}
+		case ext == ".svg":
+			dir = iconDir{Path: "scalable/" + context, Size: 128, Scale: 1, Type: "Scalable", MinSize: 8, MaxSize: 512}
+		default:
+			scale := 1
+			for s := 2; s <= 3; s++ {
+				if trimmed, ok := strings.CutSuffix(name, fmt.Sprintf("@%dx", s)); ok {
+					name, scale = trimmed, s
+				}
+			}
+
+			w, h, ok := pngSize(ReadFile(file))
+			if !ok {
+				return nil, &IconThemeError{file, "not a png"}
+			}
+			if w != h {
+				return nil, &IconThemeError{file, fmt.Sprintf("icons have to be square, this one is %dx%d", w, h)}
+			}
+			if w%scale != 0 {
+				return nil, &IconThemeError{file, fmt.Sprintf("a size of %d can not be scaled by %d", w, scale)}
+			}
+
+			size := w / scale
+			path := fmt.Sprintf("%dx%d", size, size)
+			if scale > 1 {
+				path += fmt.Sprintf("@%d", scale)
+			}
+			dir = iconDir{Path: path + "/" + context, Size: size, Scale: scale, Type: "Threshold"}
+		}
+		dir.Context = iconContexts[context]
+
+		if !iconName.MatchString(name) {
+			return nil, &IconThemeError{file, "icon names may only contain letters, digits, '-', '_', '.' and '+'"}
+		}
+
+		target := dir.Path + "/" + name + ext
+		if other, ok := sources[target]; ok {
+			return nil, &IconThemeError{file, fmt.Sprintf("%s is also placed at %s", other, target)}
+		}
+		sources[target] = file
+		dirs[dir.Path] = dir
+	}
+
+	Rm(theme)
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	for target, file := range sources {
+		path := filepath.Join(theme, filepath.FromSlash(target))
+		Mkdir(filepath.Dir(path))
+		Copy(file, path)
+		written = append(written, path)
+	}
+
+	paths := make([]string, 0, len(dirs))
+	for path := range dirs {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var index strings.Builder
+	index.WriteString("[Icon Theme]\n")
+	index.WriteString("Name=Hicolor\n")
+	index.WriteString("Comment=Fallback icon theme\n")
+	index.WriteString("Hidden=true\n")
+	index.WriteString("Directories=" + strings.Join(paths, ",") + "\n")
+
+	for _, path := range paths {
+		dir := dirs[path]
+		index.WriteString("\n[" + path + "]\n")
+		index.WriteString(fmt.Sprintf("Size=%d\n", dir.Size))
+		if dir.Scale > 1 {
+			index.WriteString(fmt.Sprintf("Scale=%d\n", dir.Scale))
+		}
+		index.WriteString("Context=" + dir.Context + "\n")
+		index.WriteString("Type=" + dir.Type + "\n")
+		if dir.Type == "Scalable" {
+			index.WriteString(fmt.Sprintf("MinSize=%d\n", dir.MinSize))
+			index.WriteString(fmt.Sprintf("MaxSize=%d\n", dir.MaxSize))
+		}
+	}
+
+	indexPath := filepath.Join(theme, "index.theme")
+	if err := os.WriteFile(indexPath, []byte(index.String()), 0644); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(written)
+	return append(written, indexPath), nil
+}
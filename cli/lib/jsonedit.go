@@ -0,0 +1,453 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// jsonScanner locates the objects of a JSON document which may contain
+// comments, trailing commas, single quoted strings and unquoted keys,
+// so that the document can be edited without losing its formatting
+type jsonScanner struct {
+	src []byte
+	pos int
+}
+
+type jsonObject struct {
+	open, close int // positions of the braces
+	members     []jsonMember
+}
+
+type jsonMember struct {
+	key        string
+	start, end int // from the key to the end of the value
+	value      int // start of the value
+	comma      int // position of the trailing comma, -1 if there is none
+	object     *jsonObject
+	leading    int // start of a block comment right before the key, start if there is none
+}
+
+type jsonEdit struct {
+	start, end int
+	text       string
+}
+
+func scanJson(src []byte) (root *jsonObject, err error) {
+	s := &jsonScanner{src: src}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(*dataError); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	s.skip()
+	if s.peek() != '{' {
+		s.fail("expected an object")
+	}
+	return s.value(), nil
+}
+
+func (s *jsonScanner) fail(msg string) {
+	panic(&dataError{bytes.Count(s.src[:min(s.pos, len(s.src))], []byte("\n")) + 1, msg})
+}
+
+func (s *jsonScanner) peek() byte {
+	if s.pos >= len(s.src) {
+		return 0
+	}
+	return s.src[s.pos]
+}
+
+func (s *jsonScanner) expect(c byte) {
+	if s.peek() != c {
+		s.fail("expected " + string(c))
+	}
+	s.pos++
+}
+
+// skips whitespace and comments
+func (s *jsonScanner) skip() {
+	for s.pos < len(s.src) {
+		rest := s.src[s.pos:]
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(rest[0])):
+			s.pos++
+		case bytes.HasPrefix(rest, []byte("//")):
+			if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+				s.pos += i
+			} else {
+				s.pos = len(s.src)
+			}
+		case bytes.HasPrefix(rest, []byte("/*")):
+			i := bytes.Index(rest, []byte("*/"))
+			if i < 0 {
+				s.fail("unterminated comment")
+			}
+			s.pos += i + 2
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) str() string {
+	start := s.pos
+	quote := s.src[s.pos]
+	for s.pos++; s.peek() != quote; s.pos++ {
+		switch s.peek() {
+		case 0, '\n':
+			s.fail("unterminated string")
+		case '\\':
+			s.pos++
+		}
+	}
+	s.pos++
+
+	raw := string(s.src[start+1 : s.pos-1])
+	if quote == '"' {
+		var str string
+		if err := json.Unmarshal(s.src[start:s.pos], &str); err == nil {
+			return str
+		}
+	}
+	return raw
+}
+
+func (s *jsonScanner) key() string {
+	if c := s.peek(); c == '"' || c == '\'' {
+		return s.str()
+	}
+
+	start := s.pos
+	for c := s.peek(); c == '_' || c == '$' || isBareKeyChar(c); c = s.peek() {
+		s.pos++
+	}
+	if start == s.pos {
+		s.fail("expected a key")
+	}
+	return string(s.src[start:s.pos])
+}
+
+// skips a value, returns it if it is an object
+func (s *jsonScanner) value() *jsonObject {
+	switch s.peek() {
+	case '{':
+		return s.object()
+	case '[':
+		s.pos++
+		for s.skip(); s.peek() != ']'; s.skip() {
+			s.value()
+			s.skip()
+			if s.peek() != ',' {
+				break
+			}
+			s.pos++
+		}
+		s.expect(']')
+	case '"', '\'':
+		s.str()
+	default:
+		start := s.pos
+		for c := s.peek(); c != 0 && !strings.ContainsRune(" \t\r\n,]}/", rune(c)); c = s.peek() {
+			s.pos++
+		}
+		if start == s.pos {
+			s.fail("expected a value")
+		}
+	}
+	return nil
+}
+
+func (s *jsonScanner) object() *jsonObject {
+	obj := &jsonObject{open: s.pos}
+	s.pos++
+
+	for s.skip(); s.peek() != '}'; s.skip() {
+		m := jsonMember{start: s.pos, comma: -1, leading: leadingComment(s.src, s.pos)}
+		m.key = s.key()
+		s.skip()
+		s.expect(':')
+		s.skip()
+		m.value = s.pos
+		m.object = s.value()
+		m.end = s.pos
+
+		s.skip()
+		if s.peek() == ',' {
+			m.comma = s.pos
+			s.pos++
+		}
+		obj.members = append(obj.members, m)
+
+		if m.comma < 0 {
+			s.skip()
+			break
+		}
+	}
+
+	obj.close = s.pos
+	s.expect('}')
+	return obj
+}
+
+func (o *jsonObject) member(key string) (jsonMember, bool) {
+	i := slices.IndexFunc(o.members, func(m jsonMember) bool { return m.key == key })
+	if i < 0 {
+		return jsonMember{}, false
+	}
+	return o.members[i], true
+}
+
+// nested object at path, nil if there is none
+func (o *jsonObject) find(path ...string) *jsonObject {
+	for _, key := range path {
+		m, ok := o.member(key)
+		if !ok || m.object == nil {
+			return nil
+		}
+		o = m.object
+	}
+	return o
+}
+
+// start of a block comment which ends right before pos on the same line
+func leadingComment(src []byte, pos int) int {
+	before := bytes.TrimRight(src[:pos], " \t")
+	if !bytes.HasSuffix(before, []byte("*/")) {
+		return pos
+	}
+	if i := bytes.LastIndex(before, []byte("/*")); i >= 0 && !bytes.ContainsRune(before[i:], '\n') {
+		return i
+	}
+	return pos
+}
+
+// whitespace at the start of the line of pos
+func indentOf(src []byte, pos int) string {
+	start := bytes.LastIndexByte(src[:pos], '\n') + 1
+	end := start
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return string(src[start:end])
+}
+
+// end of the line of pos if only whitespace and a line comment follow pos
+func lineEnd(src []byte, pos int) int {
+	end := pos
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	if bytes.HasPrefix(src[end:], []byte("//")) {
+		if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+			return end + i
+		}
+		return len(src)
+	}
+	if end == len(src) || src[end] == '\n' || src[end] == '\r' {
+		return end
+	}
+	return pos
+}
+
+func applyJsonEdits(src []byte, edits []jsonEdit) []byte {
+	slices.SortStableFunc(edits, func(a, b jsonEdit) int { return b.start - a.start })
+
+	out := slices.Clone(src)
+	for _, e := range edits {
+		out = slices.Concat(out[:e.start], []byte(e.text), out[e.end:])
+	}
+	return out
+}
+
+// edit which inserts members at the end of obj,
+// entries are "key": value pairs without indentation
+func insertMembers(src []byte, obj *jsonObject, indent string, entries []string) jsonEdit {
+	if len(obj.members) == 0 {
+		text := ""
+		for i, e := range entries {
+			if i > 0 {
+				text += ","
+			}
+			text += "\n" + indent + e
+		}
+
+		// comments in the object are kept
+		between := src[obj.open+1 : obj.close]
+		if len(bytes.TrimSpace(between)) > 0 {
+			at := obj.open + 1 + len(bytes.TrimRight(between, " \t\r\n"))
+			return jsonEdit{at, at, text}
+		}
+		return jsonEdit{obj.open + 1, obj.close, text + "\n" + indentOf(src, obj.open)}
+	}
+
+	last := obj.members[len(obj.members)-1]
+
+	// single line objects stay on a single line
+	if !bytes.ContainsRune(src[obj.open:obj.members[0].start], '\n') {
+		if last.comma >= 0 {
+			return jsonEdit{last.comma + 1, last.comma + 1, " " + strings.Join(entries, ", ") + ","}
+		}
+		return jsonEdit{last.end, last.end, ", " + strings.Join(entries, ", ")}
+	}
+
+	text := ""
+	for _, e := range entries {
+		if last.comma >= 0 {
+			text += "\n" + indent + e + ","
+		} else {
+			text += ",\n" + indent + e
+		}
+	}
+
+	if last.comma >= 0 {
+		at := lineEnd(src, last.comma+1)
+		return jsonEdit{at, at, text}
+	}
+
+	// keep a comment after the last member on its line
+	at := lineEnd(src, last.end)
+	if at == last.end {
+		return jsonEdit{at, at, text}
+	}
+	return jsonEdit{last.end, at, "," + string(src[last.end:at]) + text[1:]}
+}
+
+// edits which remove the members of obj with the given keys,
+// members which are alone on their line are removed with the line
+func removeMembers(src []byte, obj *jsonObject, keys map[string]bool) []jsonEdit {
+	var edits []jsonEdit
+	var kept []jsonMember
+	for _, m := range obj.members {
+		if !keys[m.key] {
+			kept = append(kept, m)
+			continue
+		}
+
+		start, end := m.leading, m.end
+		if m.comma >= 0 {
+			end = m.comma + 1
+		}
+
+		lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+		e := lineEnd(src, end)
+		switch {
+		case len(bytes.TrimSpace(src[lineStart:start])) == 0 && e < len(src) && (src[e] == '\n' || src[e] == '\r'):
+			start, end = lineStart, e+bytes.IndexByte(src[e:], '\n')+1
+		// on a line with other members a single space is left between them
+		case m.comma >= 0:
+			for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+				end++
+			}
+		default:
+			for start > lineStart && (src[start-1] == ' ' || src[start-1] == '\t') {
+				start--
+			}
+		}
+		edits = append(edits, jsonEdit{start, end, ""})
+	}
+
+	// a comma is left behind when the last member is removed
+	last := obj.members[len(obj.members)-1]
+	if len(kept) > 0 && keys[last.key] && last.comma < 0 && kept[len(kept)-1].comma >= 0 {
+		comma := kept[len(kept)-1].comma
+		edits = append(edits, jsonEdit{comma, comma + 1, ""})
+	}
+	return edits
+}
+
+// text of an object with the given "key": value pairs, indent is the one of its key
+func objectText(indent, unit string, entries []string) string {
+	text := "{"
+	for i, e := range entries {
+		if i > 0 {
+			text += ","
+		}
+		text += "\n" + indent + unit + e
+	}
+	return text + "\n" + indent + "}"
+}
+
+// indentation of the members of the root object
+func indentUnit(src []byte, root *jsonObject) string {
+	if len(root.members) > 0 && bytes.ContainsRune(src[root.open:root.members[0].start], '\n') {
+		return indentOf(src, root.members[0].start)
+	}
+	return "  "
+}
+
+// reindents the lines after the first one from one indentation to another,
+// the levels nested below it are converted from the unit of one to the other
+func reindent(text, from, fromUnit, to, toUnit string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		rest, ok := strings.CutPrefix(lines[i], from)
+		if !ok {
+			continue
+		}
+		depth := 0
+		for ; fromUnit != "" && strings.HasPrefix(rest, fromUnit); depth++ {
+			rest = rest[len(fromUnit):]
+		}
+		lines[i] = to + strings.Repeat(toUnit, depth) + rest
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentation of the members of obj
+func memberIndent(src []byte, obj *jsonObject, unit string) string {
+	if len(obj.members) > 0 && bytes.ContainsRune(src[obj.open:obj.members[0].start], '\n') {
+		return indentOf(src, obj.members[0].start)
+	}
+	return indentOf(src, obj.open) + unit
+}
+
+func mergeJsonObjects(dst, src []byte, a, b *jsonObject, unit, srcUnit string) []jsonEdit {
+	var entries []string
+	var edits []jsonEdit
+	indent := memberIndent(dst, a, unit)
+
+	for _, m := range b.members {
+		existing, ok := a.member(m.key)
+		if !ok {
+			entry := string(src[m.start:m.end])
+			entries = append(entries, reindent(entry, indentOf(src, m.start), srcUnit, indent, unit))
+			continue
+		}
+		if existing.object != nil && m.object != nil {
+			edits = append(edits, mergeJsonObjects(dst, src, existing.object, m.object, unit, srcUnit)...)
+		}
+	}
+
+	if len(entries) > 0 {
+		edits = append(edits, insertMembers(dst, a, indent, entries))
+	}
+	return edits
+}
+
+// MergeJson adds the keys of src missing from dst recursively, existing values are kept.
+// dst is edited in place, so its order, formatting and comments are kept.
+// Returns whether dst was changed
+func MergeJson(dst, src []byte) ([]byte, bool, error) {
+	a, err := scanJson(dst)
+	if err != nil {
+		return nil, false, err
+	}
+	b, err := scanJson(src)
+	if err != nil {
+		return nil, false, err
+	}
+
+	edits := mergeJsonObjects(dst, src, a, b, indentUnit(dst, a), indentUnit(src, b))
+	if len(edits) == 0 {
+		return dst, false, nil
+	}
+	return applyJsonEdits(dst, edits), true, nil
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeJson(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  string
+		src  string
+		want string // empty if dst is unchanged
+		err  string
+	}{
+		{
+			name: "nothing missing",
+			dst:  "{\n  \"b\": 1,\n  \"a\": { \"x\": 2 }\n}\n",
+			src:  `{"a": {"x": 3}, "b": 2}`,
+		},
+		{
+			name: "keeps order, comments and escapes",
+			dst:  "{\n  // scripts\n  \"scripts\": { \"build\": \"a && b\" },\n  \"name\": \"x\" // the name\n}\n",
+			src:  "{\n  \"name\": \"y\",\n  \"type\": \"module\",\n  \"dependencies\": {\n    \"ags\": \"*\"\n  }\n}",
+			want: "{\n  // scripts\n  \"scripts\": { \"build\": \"a && b\" },\n  \"name\": \"x\", // the name\n  \"type\": \"module\",\n  \"dependencies\": {\n    \"ags\": \"*\"\n  }\n}\n",
+		},
+		{
+			name: "nested",
+			dst:  "{\n    \"compilerOptions\": {\n        \"strict\": false,\n    },\n}",
+			src:  `{"compilerOptions": {"strict": true, "jsx": "react-jsx"}}`,
+			want: "{\n    \"compilerOptions\": {\n        \"strict\": false,\n        \"jsx\": \"react-jsx\",\n    },\n}",
+		},
+		{
+			name: "empty object",
+			dst:  "{\n  \"a\": {}\n}",
+			src:  `{"a": {"b": 1, "c": 2}}`,
+			want: "{\n  \"a\": {\n    \"b\": 1,\n    \"c\": 2\n  }\n}",
+		},
+		{
+			name: "empty root",
+			dst:  "{}",
+			src:  "{\n    \"a\": [\n        1\n    ]\n}",
+			want: "{\n  \"a\": [\n    1\n  ]\n}",
+		},
+		{
+			name: "nested with other indentation",
+			dst:  "{\n  \"a\": {\n    \"x\": 1\n  }\n}",
+			src:  "{\n\t\"a\": {\n\t\t\"y\": {\n\t\t\t\"z\": [\n\t\t\t\t1\n\t\t\t]\n\t\t}\n\t}\n}",
+			want: "{\n  \"a\": {\n    \"x\": 1,\n    \"y\": {\n      \"z\": [\n        1\n      ]\n    }\n  }\n}",
+		},
+		{
+			name: "comment in empty object",
+			dst:  "{\n  // nothing\n}",
+			src:  `{"a": 1}`,
+			want: "{\n  // nothing\n  \"a\": 1\n}",
+		},
+		{
+			name: "single line",
+			dst:  `{ "a": 1 }`,
+			src:  `{"b": 2}`,
+			want: `{ "a": 1, "b": 2 }`,
+		},
+		{
+			name: "json5",
+			dst:  "{\n  a: 'x}',\n  /* block */ b: [1, {c: 2}],\n}",
+			src:  `{"a": 1, "d": null}`,
+			want: "{\n  a: 'x}',\n  /* block */ b: [1, {c: 2}],\n  \"d\": null,\n}",
+		},
+		{name: "not an object", dst: "[]", src: "{}", err: "expected an object"},
+		{name: "unterminated", dst: "{\n  \"a\": 1", src: "{}", err: "line 2: expected }"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, changed, err := MergeJson([]byte(test.dst), []byte(test.src))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			want := test.want
+			if want == "" {
+				want = test.dst
+			}
+			if changed != (test.want != "") {
+				t.Errorf("got changed %v", changed)
+			}
+			if string(got) != want {
+				t.Fatalf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+type MetafileImport struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	External bool   `json:"external"`
+	Original string `json:"original"`
+}
+
+type MetafileInput struct {
+	Bytes   int              `json:"bytes"`
+	Imports []MetafileImport `json:"imports"`
+}
+
+type MetafileOutputInput struct {
+	BytesInOutput int `json:"bytesInOutput"`
+}
+
+type MetafileOutput struct {
+	Bytes      int                            `json:"bytes"`
+	EntryPoint string                         `json:"entryPoint"`
+	Inputs     map[string]MetafileOutputInput `json:"inputs"`
+}
+
+// Metafile is the subset of esbuild's metafile the CLI makes use of
+type Metafile struct {
+	Inputs  map[string]MetafileInput  `json:"inputs"`
+	Outputs map[string]MetafileOutput `json:"outputs"`
+}
+
+func ParseMetafile(metafile string) Metafile {
+	var meta Metafile
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		Err(err)
+	}
+	return meta
+}
+
+// GiImport is a "gi://Namespace?version=X" import of a module
+type GiImport struct {
+	Namespace string
+	Version   string
+	Importer  string
+	Dynamic   bool
+}
+
+// ParseGiImport parses a "gi://" specifier, version is empty when not specified
+func ParseGiImport(specifier string) (GiImport, bool) {
+	rest, ok := strings.CutPrefix(specifier, "gi://")
+	if !ok {
+		return GiImport{}, false
+	}
+
+	ns, query, _ := strings.Cut(rest, "?")
+	values, _ := url.ParseQuery(query)
+	return GiImport{Namespace: ns, Version: values.Get("version")}, ns != ""
+}
+
+// GiImports returns every "gi://" import in the module graph sorted by importer
+func (m Metafile) GiImports() []GiImport {
+	var imports []GiImport
+	for file, input := range m.Inputs {
+		for _, imp := range input.Imports {
+			if gi, ok := ParseGiImport(imp.Path); ok {
+				gi.Importer = file
+				gi.Dynamic = imp.Kind == "dynamic-import"
+				imports = append(imports, gi)
+			}
+		}
+	}
+
+	slices.SortFunc(imports, func(a, b GiImport) int {
+		return strings.Compare(a.Importer+a.Namespace, b.Importer+b.Namespace)
+	})
+
+	return imports
+}
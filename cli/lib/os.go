@@ -2,8 +2,11 @@ package lib
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sync"
 )
 
 var r = "\x1b[0m"
@@ -50,6 +53,26 @@ func WriteFile(path string, content string) {
 	}
 }
 
+// WriteIfChanged writes content to path unless the file already has it,
+// generated files are only touched when they change so that watchers
+// and editors are not triggered needlessly. Returns whether path was written
+func WriteIfChanged(path, content string) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && string(existing) == content {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	err := os.WriteFile(path, []byte(content), 0644)
+	return err == nil, err
+}
+
+// writes declarations next to source files, failing silently
+// as the source directory might not be writable e.g a nix store
+func writeSourceDts(path, content string) {
+	WriteIfChanged(path, content)
+}
+
 func ReadFile(path string) []byte {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -101,5 +124,77 @@ func Err(err any) {
 	case error:
 		fmt.Fprintln(os.Stderr, Red("error: ")+v.Error())
 	}
-	os.Exit(1)
+	Exit(1)
+}
+
+var (
+	exitMu    sync.Mutex
+	exitHooks []func()
+)
+
+// AtExit registers f to run when the cli exits through Exit or Err,
+// the hooks run in the reverse order of their registration
+func AtExit(f func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, f)
+}
+
+// Exit runs the hooks registered with AtExit and exits with code
+func Exit(code int) {
+	exitMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitMu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+	os.Exit(code)
+}
+
+// TempDir creates a temporary directory, which is removed by cleanup
+// or when the cli exits through Exit or Err, which do not run deferred calls
+func TempDir(pattern string) (dir string, cleanup func()) {
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		Err(err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	AtExit(cleanup)
+	return dir, cleanup
+}
+
+// Copy copies a file or recursively a directory, existing files are overwritten
+func Copy(src, dst string) {
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+	if err != nil {
+		Err(err)
+	}
 }
@@ -0,0 +1,409 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Color is an sRGB color with channels between 0 and 1
+type Color struct{ R, G, B float64 }
+
+func (c Color) Hex() string {
+	channel := func(v float64) int { return int(math.Round(math.Max(0, math.Min(1, v)) * 255)) }
+	return fmt.Sprintf("#%02x%02x%02x", channel(c.R), channel(c.G), channel(c.B))
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// Luminance is the relative luminance of WCAG
+// https://www.w3.org/TR/WCAG21/#dfn-relative-luminance
+func (c Color) Luminance() float64 {
+	return 0.2126*srgbToLinear(c.R) + 0.7152*srgbToLinear(c.G) + 0.0722*srgbToLinear(c.B)
+}
+
+// Contrast is the contrast ratio of WCAG between two colors, from 1 to 21
+func Contrast(a, b Color) float64 {
+	la, lb := a.Luminance(), b.Luminance()
+	return (math.Max(la, lb) + 0.05) / (math.Min(la, lb) + 0.05)
+}
+
+// oklch is a color in the polar form of oklab, hue is in degrees
+// https://bottosson.github.io/posts/oklab/
+type oklch struct{ L, C, H float64 }
+
+func (c Color) oklch() oklch {
+	r, g, b := srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	L := 0.2104542553*l + 0.7936177850*m - 0.0040720468*s
+	A := 1.9779984951*l - 2.4285922050*m + 0.4505937099*s
+	B := 0.0259040371*l + 0.7827717662*m - 0.8086757660*s
+
+	h := math.Atan2(B, A) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return oklch{L, math.Hypot(A, B), h}
+}
+
+// linear srgb of the color which can be outside of the gamut
+func (c oklch) linear() (float64, float64, float64) {
+	a, b := c.C*math.Cos(c.H*math.Pi/180), c.C*math.Sin(c.H*math.Pi/180)
+	l := math.Pow(c.L+0.3963377774*a+0.2158037573*b, 3)
+	m := math.Pow(c.L-0.1055613458*a-0.0638541728*b, 3)
+	s := math.Pow(c.L-0.0894841775*a-1.2914855480*b, 3)
+
+	return +4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s
+}
+
+// srgb of the color, the chroma is reduced until it fits into the gamut
+func (c oklch) srgb() Color {
+	inGamut := func(c oklch) bool {
+		r, g, b := c.linear()
+		const eps = 1e-6
+		return r >= -eps && r <= 1+eps && g >= -eps && g <= 1+eps && b >= -eps && b <= 1+eps
+	}
+
+	if !inGamut(c) {
+		low, high := 0.0, c.C
+		for high-low > 1e-4 {
+			c.C = (low + high) / 2
+			if inGamut(c) {
+				low = c.C
+			} else {
+				high = c.C
+			}
+		}
+		c.C = low
+	}
+
+	r, g, b := c.linear()
+	return Color{linearToSrgb(math.Max(0, r)), linearToSrgb(math.Max(0, g)), linearToSrgb(math.Max(0, b))}
+}
+
+// Swatch is a color of an image and the share of the pixels it stands for
+type Swatch struct {
+	Color      Color
+	Population float64
+}
+
+// colors of a bucket of the median cut
+type colorBox []Color
+
+func (box colorBox) widest() (int, float64) {
+	channel, widest := 0, -1.0
+	for ch := 0; ch < 3; ch++ {
+		low, high := 1.0, 0.0
+		for _, c := range box {
+			v := [3]float64{c.R, c.G, c.B}[ch]
+			low, high = math.Min(low, v), math.Max(high, v)
+		}
+		if high-low > widest {
+			channel, widest = ch, high-low
+		}
+	}
+	return channel, widest
+}
+
+func (box colorBox) mean() Color {
+	var r, g, b float64
+	for _, c := range box {
+		r, g, b = r+c.R, g+c.G, b+c.B
+	}
+	n := float64(len(box))
+	return Color{r / n, g / n, b / n}
+}
+
+// Quantize reduces an image to at most n colors with a median cut,
+// large images are sampled and transparent pixels are ignored
+func Quantize(img image.Image, n int) []Swatch {
+	bounds := img.Bounds()
+	step := max(1, int(math.Sqrt(float64(bounds.Dx()*bounds.Dy())/(256*256))))
+
+	var pixels colorBox
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			// colors are premultiplied
+			pixels = append(pixels, Color{float64(r) / float64(a), float64(g) / float64(a), float64(b) / float64(a)})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	boxes := []colorBox{pixels}
+	for len(boxes) < n {
+		// the box with the widest channel weighted by its size is split
+		i, score := -1, 0.0
+		for j, box := range boxes {
+			if _, width := box.widest(); len(box) > 1 && width > 0 && width*float64(len(box)) > score {
+				i, score = j, width*float64(len(box))
+			}
+		}
+		if i == -1 {
+			break
+		}
+
+		box := boxes[i]
+		channel, _ := box.widest()
+		slices.SortFunc(box, func(a, b Color) int {
+			va, vb := [3]float64{a.R, a.G, a.B}[channel], [3]float64{b.R, b.G, b.B}[channel]
+			switch {
+			case va < vb:
+				return -1
+			case va > vb:
+				return 1
+			}
+			return 0
+		})
+		boxes = append(boxes[:i], append([]colorBox{box[:len(box)/2], box[len(box)/2:]}, boxes[i+1:]...)...)
+	}
+
+	swatches := make([]Swatch, len(boxes))
+	for i, box := range boxes {
+		swatches[i] = Swatch{box.mean(), float64(len(box)) / float64(len(pixels))}
+	}
+	slices.SortStableFunc(swatches, func(a, b Swatch) int {
+		switch {
+		case a.Population > b.Population:
+			return -1
+		case a.Population < b.Population:
+			return 1
+		}
+		return 0
+	})
+	return swatches
+}
+
+// LoadImage decodes a png, jpeg or gif file
+func LoadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// PaletteRole is a named color of a Scheme
+type PaletteRole struct {
+	Name  string
+	Color Color
+}
+
+// Scheme is a light or dark set of colors,
+// every "on-" role reaches a contrast of 4.5 against the role it is drawn on
+type Scheme []PaletteRole
+
+// Palette is a light and a dark scheme derived from the swatches of an image
+type Palette struct {
+	Swatches []Swatch
+	Light    Scheme
+	Dark     Scheme
+}
+
+// hues of the scheme, colorful swatches which cover a lot of the image are preferred,
+// the other hues are picked to be distinct from each other
+func paletteHues(swatches []Swatch) (float64, float64, float64, float64) {
+	type candidate struct{ Hue, Chroma, Score float64 }
+	var candidates []candidate
+	for _, s := range swatches {
+		c := s.Color.oklch()
+		if c.C < 0.03 || c.L < 0.15 || c.L > 0.95 {
+			continue
+		}
+		candidates = append(candidates, candidate{c.H, c.C, s.Population * math.Min(c.C, 0.2)})
+	}
+
+	if len(candidates) == 0 {
+		// a gray image, the scheme is neutral as well
+		hue := 0.0
+		if len(swatches) > 0 {
+			hue = swatches[0].Color.oklch().H
+		}
+		return hue, 0, hue + 60, hue - 60
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+
+	hueDistance := func(a, b float64) float64 {
+		d := math.Mod(math.Abs(a-b), 360)
+		return math.Min(d, 360-d)
+	}
+
+	primary := candidates[0]
+	hues := []float64{primary.Hue}
+	for _, fallback := range []float64{60, -60} {
+		hue := primary.Hue + fallback
+		for _, c := range candidates[1:] {
+			if !slices.ContainsFunc(hues, func(h float64) bool { return hueDistance(h, c.Hue) < 30 }) {
+				hue = c.Hue
+				break
+			}
+		}
+		hues = append(hues, math.Mod(hue+360, 360))
+	}
+
+	return primary.Hue, primary.Chroma, hues[1], hues[2]
+}
+
+// adjusts the lightness of fg until it reaches the contrast ratio against bg
+func ensureContrast(fg oklch, bg Color, ratio float64) Color {
+	step := 0.01
+	if bg.Luminance() > 0.18 {
+		step = -0.01
+	}
+	for Contrast(fg.srgb(), bg) < ratio && fg.L >= 0 && fg.L <= 1 {
+		fg.L += step
+	}
+	fg.L = math.Max(0, math.Min(1, fg.L))
+	return fg.srgb()
+}
+
+// NewPalette derives a light and a dark scheme from the swatches of an image
+func NewPalette(swatches []Swatch) Palette {
+	primary, chroma, secondary, tertiary := paletteHues(swatches)
+	accent := math.Max(0.04, math.Min(chroma, 0.15))
+	neutral := math.Min(chroma, 0.02)
+	if chroma == 0 {
+		accent, neutral = 0, 0
+	}
+
+	const errorHue = 25
+
+	scheme := func(dark bool) Scheme {
+		pick := func(l, d float64) float64 {
+			if dark {
+				return d
+			}
+			return l
+		}
+
+		background := oklch{pick(0.98, 0.18), neutral, primary}.srgb()
+		surface := oklch{pick(0.95, 0.22), neutral, primary}.srgb()
+		surfaceVariant := oklch{pick(0.90, 0.28), neutral * 1.5, primary}.srgb()
+
+		var s Scheme
+		add := func(name string, c Color) { s = append(s, PaletteRole{name, c}) }
+
+		add("background", background)
+		add("on-background", ensureContrast(oklch{pick(0.20, 0.93), neutral / 2, primary}, background, 7))
+		add("surface", surface)
+		add("on-surface", ensureContrast(oklch{pick(0.20, 0.93), neutral / 2, primary}, surface, 7))
+		add("surface-variant", surfaceVariant)
+		add("on-surface-variant", ensureContrast(oklch{pick(0.40, 0.80), neutral, primary}, surfaceVariant, 4.5))
+		add("outline", ensureContrast(oklch{pick(0.60, 0.55), neutral, primary}, background, 3))
+
+		for _, role := range []struct {
+			Name   string
+			Hue    float64
+			Chroma float64
+		}{
+			{"primary", primary, accent},
+			{"secondary", secondary, accent * 0.6},
+			{"tertiary", tertiary, accent * 0.8},
+			{"error", errorHue, 0.18},
+		} {
+			color := ensureContrast(oklch{pick(0.50, 0.78), role.Chroma, role.Hue}, background, 3)
+			add(role.Name, color)
+			add("on-"+role.Name, ensureContrast(oklch{pick(0.99, 0.22), role.Chroma / 3, role.Hue}, color, 4.5))
+		}
+
+		return s
+	}
+
+	return Palette{Swatches: swatches, Light: scheme(false), Dark: scheme(true)}
+}
+
+// Scss is a partial with a map of each scheme and the variables of the default one
+func (p Palette) Scss(source string, dark bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// generated by ags theme generate from %s\n\n", source)
+
+	b.WriteString("$swatches: (")
+	for i, s := range p.Swatches {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(s.Color.Hex())
+	}
+	b.WriteString(");\n")
+
+	for _, scheme := range []struct {
+		Name   string
+		Scheme Scheme
+	}{{"light", p.Light}, {"dark", p.Dark}} {
+		fmt.Fprintf(&b, "\n$%s: (\n", scheme.Name)
+		for _, role := range scheme.Scheme {
+			fmt.Fprintf(&b, "  \"%s\": %s,\n", role.Name, role.Color.Hex())
+		}
+		b.WriteString(");\n")
+	}
+
+	scheme := p.Light
+	if dark {
+		scheme = p.Dark
+	}
+	b.WriteString("\n")
+	for _, role := range scheme {
+		fmt.Fprintf(&b, "$%s: %s !default;\n", role.Name, role.Color.Hex())
+	}
+
+	return b.String()
+}
+
+// Css defines the colors of the default scheme with @define-color of gtk
+func (p Palette) Css(source string, dark bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "/* generated by ags theme generate from %s */\n\n", source)
+
+	scheme := p.Light
+	if dark {
+		scheme = p.Dark
+	}
+	for _, role := range scheme {
+		fmt.Fprintf(&b, "@define-color %s %s;\n", strings.ReplaceAll(role.Name, "-", "_"), role.Color.Hex())
+	}
+
+	return b.String()
+}
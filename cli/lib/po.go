@@ -0,0 +1,440 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PoEntry is a message of a gettext catalog
+// https://www.gnu.org/software/gettext/manual/html_node/PO-Files.html
+type PoEntry struct {
+	Comments    []string // "# " written by translators
+	Extracted   []string // "#." extracted from the sources
+	References  []string // "#:" file:line where the message is used
+	Flags       []string // "#," e.g fuzzy
+	HasContext  bool
+	Context     string
+	Msgid       string
+	MsgidPlural string
+	Msgstr      []string // one per plural form
+	Obsolete    bool
+}
+
+// key of the message in a catalog, the context is separated by \x04
+func (e *PoEntry) key() string {
+	if e.HasContext {
+		return e.Context + "\x04" + e.Msgid
+	}
+	return e.Msgid
+}
+
+func (e *PoEntry) translated() bool {
+	return slices.ContainsFunc(e.Msgstr, func(s string) bool { return s != "" }) &&
+		!slices.Contains(e.Flags, "fuzzy")
+}
+
+// Po is a gettext catalog, the header is the entry with an empty msgid
+type Po struct {
+	Entries []*PoEntry
+}
+
+func (p *Po) find(key string) *PoEntry {
+	for _, e := range p.Entries {
+		if e.key() == key {
+			return e
+		}
+	}
+	return nil
+}
+
+// Header returns the value of a field of the header entry
+func (p *Po) Header(field string) string {
+	header := p.find("")
+	if header == nil || len(header.Msgstr) == 0 {
+		return ""
+	}
+	for _, line := range strings.Split(header.Msgstr[0], "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, field) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+var poPlurals = regexp.MustCompile(`nplurals\s*=\s*(\d+)`)
+
+// number of plural forms of the language of the catalog
+func (p *Po) nplurals() int {
+	if m := poPlurals.FindStringSubmatch(p.Header("Plural-Forms")); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 2
+}
+
+// PoError is a syntax error in a catalog
+type PoError struct {
+	Line int
+	Msg  string
+}
+
+func (e *PoError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+func poUnquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+
+	var b strings.Builder
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), true
+}
+
+func poQuote(s string) string {
+	return `"` + strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\t", `\t`,
+		"\r", `\r`,
+	).Replace(s) + `"`
+}
+
+// ParsePo parses the content of a .po or .pot file
+func ParsePo(content string) (*Po, error) {
+	po := &Po{}
+	var entry *PoEntry
+	var field *string
+	done := false // the msgstr of entry was read, the next keyword starts a new entry
+
+	next := func() *PoEntry {
+		if entry == nil || done {
+			entry = &PoEntry{}
+			po.Entries = append(po.Entries, entry)
+			done = false
+		}
+		return entry
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		obsolete := false
+		if rest, ok := strings.CutPrefix(line, "#~"); ok {
+			line, obsolete = strings.TrimSpace(rest), true
+		}
+
+		switch {
+		case line == "":
+			if entry != nil && len(entry.Msgstr) > 0 {
+				done = true
+			}
+			field = nil
+			continue
+		case strings.HasPrefix(line, "#|"):
+			continue
+		case strings.HasPrefix(line, "#"):
+			if entry != nil && len(entry.Msgstr) > 0 {
+				done = true
+			}
+			e := next()
+			switch {
+			case strings.HasPrefix(line, "#."):
+				e.Extracted = append(e.Extracted, strings.TrimSpace(line[2:]))
+			case strings.HasPrefix(line, "#:"):
+				e.References = append(e.References, strings.Fields(line[2:])...)
+			case strings.HasPrefix(line, "#,"):
+				for _, flag := range strings.Split(line[2:], ",") {
+					if flag = strings.TrimSpace(flag); flag != "" {
+						e.Flags = append(e.Flags, flag)
+					}
+				}
+			default:
+				e.Comments = append(e.Comments, strings.TrimPrefix(line[1:], " "))
+			}
+			field = nil
+			continue
+		case strings.HasPrefix(line, `"`):
+			s, ok := poUnquote(line)
+			if !ok || field == nil {
+				return nil, &PoError{i + 1, "unexpected string"}
+			}
+			*field += s
+			continue
+		}
+
+		keyword, value, _ := strings.Cut(line, " ")
+		s, ok := poUnquote(strings.TrimSpace(value))
+		if !ok {
+			return nil, &PoError{i + 1, "expected a string after " + keyword}
+		}
+
+		if (keyword == "msgctxt" || keyword == "msgid") && entry != nil && len(entry.Msgstr) > 0 {
+			done = true
+		}
+
+		e := next()
+		e.Obsolete = e.Obsolete || obsolete
+
+		switch {
+		case keyword == "msgctxt":
+			e.HasContext, e.Context = true, s
+			field = &e.Context
+		case keyword == "msgid":
+			e.Msgid = s
+			field = &e.Msgid
+		case keyword == "msgid_plural":
+			e.MsgidPlural = s
+			field = &e.MsgidPlural
+		case keyword == "msgstr":
+			e.Msgstr = []string{s}
+			field = &e.Msgstr[0]
+		case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+			n, err := strconv.Atoi(keyword[7 : len(keyword)-1])
+			if err != nil || n != len(e.Msgstr) {
+				return nil, &PoError{i + 1, "unexpected " + keyword}
+			}
+			e.Msgstr = append(e.Msgstr, s)
+			field = &e.Msgstr[n]
+		default:
+			return nil, &PoError{i + 1, "unknown keyword " + keyword}
+		}
+	}
+
+	// drop comments which do not belong to a message
+	po.Entries = slices.DeleteFunc(po.Entries, func(e *PoEntry) bool {
+		return e.Msgstr == nil && e.Msgid == "" && !e.HasContext
+	})
+
+	return po, nil
+}
+
+func poWriteString(b *strings.Builder, prefix, keyword, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) <= 1 {
+		fmt.Fprintf(b, "%s%s %s\n", prefix, keyword, poQuote(s))
+		return
+	}
+
+	fmt.Fprintf(b, "%s%s \"\"\n", prefix, keyword)
+	for _, line := range lines {
+		fmt.Fprintf(b, "%s%s\n", prefix, poQuote(line))
+	}
+}
+
+// String formats the catalog as a .po file
+func (p *Po) String() string {
+	var b strings.Builder
+	for i, e := range p.Entries {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		for _, c := range e.Comments {
+			b.WriteString(strings.TrimRight("# "+c, " ") + "\n")
+		}
+		for _, c := range e.Extracted {
+			b.WriteString("#. " + c + "\n")
+		}
+		if len(e.References) > 0 {
+			b.WriteString("#: " + strings.Join(e.References, " ") + "\n")
+		}
+		if len(e.Flags) > 0 {
+			b.WriteString("#, " + strings.Join(e.Flags, ", ") + "\n")
+		}
+
+		prefix := ""
+		if e.Obsolete {
+			prefix = "#~ "
+		}
+
+		if e.HasContext {
+			poWriteString(&b, prefix, "msgctxt", e.Context)
+		}
+		poWriteString(&b, prefix, "msgid", e.Msgid)
+		if e.MsgidPlural != "" {
+			poWriteString(&b, prefix, "msgid_plural", e.MsgidPlural)
+			for n, s := range e.Msgstr {
+				poWriteString(&b, prefix, fmt.Sprintf("msgstr[%d]", n), s)
+			}
+		} else {
+			msgstr := ""
+			if len(e.Msgstr) > 0 {
+				msgstr = e.Msgstr[0]
+			}
+			poWriteString(&b, prefix, "msgstr", msgstr)
+		}
+	}
+	return b.String()
+}
+
+// Merge updates a catalog with the messages of a template, translations of messages
+// which are no longer used are kept as obsolete in case they come back
+func (p *Po) Merge(pot *Po) *Po {
+	merged := &Po{}
+	nplurals := p.nplurals()
+
+	for _, t := range pot.Entries {
+		if t.Obsolete {
+			continue
+		}
+
+		e := &PoEntry{
+			Extracted:   t.Extracted,
+			References:  t.References,
+			Flags:       slices.DeleteFunc(slices.Clone(t.Flags), func(f string) bool { return f == "fuzzy" }),
+			HasContext:  t.HasContext,
+			Context:     t.Context,
+			Msgid:       t.Msgid,
+			MsgidPlural: t.MsgidPlural,
+			Msgstr:      []string{""},
+		}
+		if t.MsgidPlural != "" {
+			e.Msgstr = make([]string, nplurals)
+		}
+
+		if old := p.find(t.key()); old != nil {
+			e.Comments = old.Comments
+			if slices.Contains(old.Flags, "fuzzy") {
+				e.Flags = append(e.Flags, "fuzzy")
+			}
+			copy(e.Msgstr, old.Msgstr)
+			if t.Msgid == "" {
+				// the header belongs to the catalog
+				e.Extracted, e.References, e.Flags = old.Extracted, old.References, old.Flags
+			}
+		}
+
+		merged.Entries = append(merged.Entries, e)
+	}
+
+	for _, old := range p.Entries {
+		if merged.find(old.key()) == nil && old.Msgid != "" && slices.ContainsFunc(old.Msgstr, func(s string) bool { return s != "" }) {
+			obsolete := *old
+			obsolete.Obsolete = true
+			obsolete.Extracted, obsolete.References = nil, nil
+			merged.Entries = append(merged.Entries, &obsolete)
+		}
+	}
+
+	return merged
+}
+
+// NewPo starts a catalog for a language from a template
+func NewPo(pot *Po, lang string) *Po {
+	header := fmt.Sprintf("Project-Id-Version: %s\n", pot.Header("Project-Id-Version")) +
+		fmt.Sprintf("Language: %s\n", lang) +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: text/plain; charset=UTF-8\n" +
+		"Content-Transfer-Encoding: 8bit\n" +
+		"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+	po := &Po{Entries: []*PoEntry{{Msgstr: []string{header}}}}
+	return po.Merge(pot)
+}
+
+// Mo compiles the translated messages of the catalog into the binary .mo format
+// https://www.gnu.org/software/gettext/manual/html_node/MO-Files.html
+func (p *Po) Mo() []byte {
+	type message struct{ key, value string }
+
+	var messages []message
+	for _, e := range p.Entries {
+		if e.Obsolete || e.Msgid != "" && !e.translated() {
+			continue
+		}
+
+		key := e.key()
+		if e.MsgidPlural != "" {
+			key += "\x00" + e.MsgidPlural
+		}
+
+		value := ""
+		if len(e.Msgstr) > 0 {
+			value = strings.Join(e.Msgstr, "\x00")
+		}
+		messages = append(messages, message{key, value})
+	}
+
+	slices.SortFunc(messages, func(a, b message) int { return strings.Compare(a.key, b.key) })
+
+	n := uint32(len(messages))
+	keys := uint32(28)
+	values := keys + 8*n
+	offset := values + 8*n
+
+	var header, data bytes.Buffer
+	for _, v := range []uint32{0x950412de, 0, n, keys, values, 0, offset} {
+		binary.Write(&header, binary.LittleEndian, v)
+	}
+
+	var table [2]bytes.Buffer
+	for i, strs := range [2]func(m message) string{
+		func(m message) string { return m.key },
+		func(m message) string { return m.value },
+	} {
+		for _, m := range messages {
+			s := strs(m)
+			binary.Write(&table[i], binary.LittleEndian, uint32(len(s)))
+			binary.Write(&table[i], binary.LittleEndian, offset+uint32(data.Len()))
+			data.WriteString(s + "\x00")
+		}
+	}
+
+	header.Write(table[0].Bytes())
+	header.Write(table[1].Bytes())
+	header.Write(data.Bytes())
+	return header.Bytes()
+}
+
+// Statistics counts the messages by their state, the header is not counted
+func (p *Po) Statistics() (translated, fuzzy, untranslated int) {
+	for _, e := range p.Entries {
+		switch {
+		case e.Obsolete || e.Msgid == "" && !e.HasContext:
+		case e.translated():
+			translated++
+		case slices.Contains(e.Flags, "fuzzy"):
+			fuzzy++
+		default:
+			untranslated++
+		}
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// IsInteractive reports whether stdin is connected to a terminal
+func IsInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func readLine() string {
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		// stdin was closed, e.g. ctrl+d
+		fmt.Println()
+		Exit(1)
+	}
+	return strings.TrimSpace(line)
+}
+
+// Prompt asks for a line of input, returns fallback on empty input
+func Prompt(question, fallback string) string {
+	if fallback != "" {
+		fmt.Printf("%s %s (%s): ", Blue("?"), question, Cyan(fallback))
+	} else {
+		fmt.Printf("%s %s: ", Blue("?"), question)
+	}
+
+	if answer := readLine(); answer != "" {
+		return answer
+	}
+	return fallback
+}
+
+// Confirm asks a yes/no question, returns fallback on empty input
+func Confirm(question string, fallback bool) bool {
+	hint := "y/N"
+	if fallback {
+		hint = "Y/n"
+	}
+
+	for {
+		fmt.Printf("%s %s (%s): ", Blue("?"), question, Cyan(hint))
+		switch strings.ToLower(readLine()) {
+		case "":
+			return fallback
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+// Select asks to pick one of the options, returns the index of the choice
+func Select(question string, options []string, fallback int) int {
+	fmt.Printf("%s %s\n", Blue("?"), question)
+	for i, option := range options {
+		fmt.Printf("  %s %s\n", Cyan(strconv.Itoa(i+1)+")"), option)
+	}
+
+	for {
+		fmt.Printf("  choice (%s): ", Cyan(strconv.Itoa(fallback+1)))
+		answer := readLine()
+		if answer == "" {
+			return fallback
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(options) {
+			return i - 1
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// minimal protocol buffers encoding for the messages of the sass embedded protocol
+// https://protobuf.dev/programming-guides/encoding/
+
+const (
+	protoVarint = 0
+	protoBytes  = 2
+)
+
+type protoField struct {
+	Num    int
+	Varint uint64
+	Bytes  []byte
+}
+
+func protoTag(b []byte, num, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(num<<3|wire))
+}
+
+func protoAppendUint(b []byte, num int, v uint64) []byte {
+	return binary.AppendUvarint(protoTag(b, num, protoVarint), v)
+}
+
+func protoAppendBool(b []byte, num int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return protoAppendUint(b, num, 1)
+}
+
+func protoAppendBytes(b []byte, num int, v []byte) []byte {
+	b = binary.AppendUvarint(protoTag(b, num, protoBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func protoAppendString(b []byte, num int, v string) []byte {
+	return protoAppendBytes(b, num, []byte(v))
+}
+
+var errProtoMalformed = errors.New("malformed protocol buffer")
+
+// decodes the fields of a message, fixed size fields are skipped
+func protoDecode(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errProtoMalformed
+		}
+		b = b[n:]
+
+		field := protoField{Num: int(tag >> 3)}
+		switch tag & 7 {
+		case protoVarint:
+			field.Varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errProtoMalformed
+			}
+			b = b[n:]
+		case protoBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return nil, errProtoMalformed
+			}
+			field.Bytes = b[n : n+int(size)]
+			b = b[n+int(size):]
+		case 1:
+			if len(b) < 8 {
+				return nil, errProtoMalformed
+			}
+			b = b[8:]
+			continue
+		case 5:
+			if len(b) < 4 {
+				return nil, errProtoMalformed
+			}
+			b = b[4:]
+			continue
+		default:
+			return nil, errProtoMalformed
+		}
+
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// the last occurrence of a field, as a field which is not repeated can be sent multiple times
+func protoGet(fields []protoField, num int) (protoField, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Num == num {
+			return fields[i], true
+		}
+	}
+	return protoField{}, false
+}
+
+func protoString(fields []protoField, num int) string {
+	f, _ := protoGet(fields, num)
+	return string(f.Bytes)
+}
+
+func protoUint(fields []protoField, num int) uint64 {
+	f, _ := protoGet(fields, num)
+	return f.Varint
+}
+
+func protoMessage(fields []protoField, num int) ([]protoField, bool) {
+	f, ok := protoGet(fields, num)
+	if !ok {
+		return nil, false
+	}
+	msg, err := protoDecode(f.Bytes)
+	return msg, err == nil
+}
+
+func protoStrings(fields []protoField, num int) []string {
+	var values []string
+	for _, f := range fields {
+		if f.Num == num {
+			values = append(values, string(f.Bytes))
+		}
+	}
+	return values
+}
@@ -0,0 +1,346 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sassResult is a compiled stylesheet
+type sassResult struct {
+	Css       string
+	SourceMap *SourceMap
+	Files     []string // every file the compilation loaded, including the stylesheet itself
+}
+
+// sassCompiler is a long-lived compiler speaking the sass embedded protocol
+// over the stdio of "sass --embedded", compilations are multiplexed by their id
+// https://github.com/sass/sass/blob/main/spec/embedded-protocol.md
+type sassCompiler struct {
+	stdin io.WriteCloser
+
+	mu      sync.Mutex
+	nextId  uint32
+	pending map[uint32]chan []byte
+	err     error
+
+	cacheMu sync.Mutex
+	cache   map[string]sassCacheEntry
+}
+
+type sassCacheEntry struct {
+	Hash   string
+	Result sassResult
+}
+
+// fields of the messages in embedded_sass.proto
+const (
+	sassInboundCompileRequest = 2
+	sassInboundVersionRequest = 7
+
+	sassCompileRequestPath       = 3
+	sassCompileRequestSourceMap  = 5
+	sassCompileRequestImporters  = 6
+	sassCompileRequestAlertColor = 8
+	sassImporterPath             = 1
+
+	sassOutboundError           = 1
+	sassOutboundCompileResponse = 2
+	sassOutboundLogEvent        = 3
+	sassOutboundVersionResponse = 8
+
+	sassCompileResponseSuccess    = 2
+	sassCompileResponseFailure    = 3
+	sassCompileResponseLoadedUrls = 4
+	sassCompileSuccessCss         = 1
+	sassCompileSuccessSourceMap   = 2
+	sassCompileFailureMessage     = 1
+	sassCompileFailureFormatted   = 4
+	sassLogEventFormatted         = 6
+	sassProtocolErrorMessage      = 3
+)
+
+var errSassUnavailable = errors.New("the sass embedded compiler is not available")
+
+// the compiler is started on the first compilation and lives as long as the cli,
+// its stdin is closed and it is waited for when the cli exits
+var sassEmbedded = sync.OnceValue(func() *sassCompiler {
+	c, err := startSassCompiler()
+	if err != nil {
+		return nil
+	}
+	return c
+})
+
+// gatedWriter discards what is written until it is opened
+type gatedWriter struct {
+	open atomic.Bool
+	w    io.Writer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	if !g.open.Load() {
+		return len(p), nil
+	}
+	return g.w.Write(p)
+}
+
+func startSassCompiler() (*sassCompiler, error) {
+	cmd := Exec("sass", "--embedded")
+	cmd.Dir = "/"
+	// children of the compiler might keep its stdio open
+	cmd.WaitDelay = time.Second
+
+	// compilers without the embedded protocol print their usage
+	stderr := &gatedWriter{w: os.Stderr}
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &sassCompiler{
+		stdin:   stdin,
+		pending: map[uint32]chan []byte{},
+		cache:   map[string]sassCacheEntry{},
+	}
+	go c.read(bufio.NewReader(stdout))
+
+	// compilers without the embedded protocol exit or print something else
+	version := c.open(0)
+	c.send(0, protoAppendBytes(nil, sassInboundVersionRequest, nil))
+
+	supported := false
+	select {
+	case msg, ok := <-version:
+		if fields, err := protoDecode(msg); ok && err == nil {
+			_, supported = protoGet(fields, sassOutboundVersionResponse)
+		}
+	case <-time.After(10 * time.Second):
+	}
+
+	if !supported {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, errSassUnavailable
+	}
+
+	c.close(0)
+	stderr.open.Store(true)
+
+	AtExit(func() {
+		stdin.Close()
+		cmd.Wait()
+	})
+	return c, nil
+}
+
+// dispatches the packets of the compiler to the compilation they belong to
+func (c *sassCompiler) read(r *bufio.Reader) {
+	err := func() error {
+		for {
+			size, err := binary.ReadUvarint(r)
+			if err != nil {
+				return err
+			}
+
+			packet := make([]byte, size)
+			if _, err := io.ReadFull(r, packet); err != nil {
+				return err
+			}
+
+			id, n := binary.Uvarint(packet)
+			if n <= 0 {
+				return errProtoMalformed
+			}
+
+			c.mu.Lock()
+			ch, ok := c.pending[uint32(id)]
+			c.mu.Unlock()
+
+			if !ok {
+				// protocol errors which do not belong to a compilation are fatal
+				fields, _ := protoDecode(packet[n:])
+				if e, ok := protoMessage(fields, sassOutboundError); ok {
+					return errors.New("sass: " + protoString(e, sassProtocolErrorMessage))
+				}
+				continue
+			}
+
+			ch <- packet[n:]
+		}
+	}()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+func (c *sassCompiler) open(id uint32) chan []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan []byte, 8)
+	if c.err != nil {
+		close(ch)
+		return ch
+	}
+	c.pending[id] = ch
+	return ch
+}
+
+func (c *sassCompiler) close(id uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+func (c *sassCompiler) send(id uint32, msg []byte) error {
+	packet := binary.AppendUvarint(nil, uint64(id))
+	packet = append(packet, msg...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	_, err := c.stdin.Write(append(binary.AppendUvarint(nil, uint64(len(packet))), packet...))
+	return err
+}
+
+// hash of the content of the files a compilation depends on
+func sassHash(files []string) string {
+	h := sha256.New()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return ""
+		}
+		h.Write([]byte(file + "\x00"))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *sassCompiler) compile(file string) (sassResult, error) {
+	c.cacheMu.Lock()
+	entry, ok := c.cache[file]
+	c.cacheMu.Unlock()
+	if ok && entry.Hash != "" && entry.Hash == sassHash(entry.Result.Files) {
+		return entry.Result, nil
+	}
+
+	c.mu.Lock()
+	c.nextId++
+	id := c.nextId
+	c.mu.Unlock()
+
+	req := protoAppendString(nil, sassCompileRequestPath, file)
+	req = protoAppendBool(req, sassCompileRequestSourceMap, true)
+	req = protoAppendBool(req, sassCompileRequestAlertColor, true)
+	req = protoAppendBytes(req, sassCompileRequestImporters,
+		protoAppendString(nil, sassImporterPath, filepath.Dir(file)))
+
+	ch := c.open(id)
+	defer c.close(id)
+
+	if err := c.send(id, protoAppendBytes(nil, sassInboundCompileRequest, req)); err != nil {
+		return sassResult{}, errSassUnavailable
+	}
+
+	for msg := range ch {
+		fields, err := protoDecode(msg)
+		if err != nil {
+			return sassResult{}, err
+		}
+
+		if log, ok := protoMessage(fields, sassOutboundLogEvent); ok {
+			fmt.Fprintln(os.Stderr, protoString(log, sassLogEventFormatted))
+			continue
+		}
+
+		if e, ok := protoMessage(fields, sassOutboundError); ok {
+			return sassResult{}, errors.New("sass: " + protoString(e, sassProtocolErrorMessage))
+		}
+
+		res, ok := protoMessage(fields, sassOutboundCompileResponse)
+		if !ok {
+			continue
+		}
+
+		if failure, ok := protoMessage(res, sassCompileResponseFailure); ok {
+			fmt.Fprintln(os.Stderr, protoString(failure, sassCompileFailureFormatted))
+			return sassResult{}, errors.New(protoString(failure, sassCompileFailureMessage))
+		}
+
+		success, _ := protoMessage(res, sassCompileResponseSuccess)
+		result := sassResult{Css: protoString(success, sassCompileSuccessCss), Files: []string{file}}
+		if sourcemap := protoString(success, sassCompileSuccessSourceMap); sourcemap != "" {
+			result.SourceMap, _ = ParseSourceMap([]byte(sourcemap))
+		}
+
+		for _, loaded := range protoStrings(res, sassCompileResponseLoadedUrls) {
+			if u, err := url.Parse(loaded); err == nil && u.Scheme == "file" && !slices.Contains(result.Files, u.Path) {
+				result.Files = append(result.Files, u.Path)
+			}
+		}
+
+		c.cacheMu.Lock()
+		c.cache[file] = sassCacheEntry{sassHash(result.Files), result}
+		c.cacheMu.Unlock()
+
+		return result, nil
+	}
+
+	return sassResult{}, errSassUnavailable
+}
+
+// compileSass compiles file with the embedded compiler,
+// falling back to a sass process per file when it is not available
+func compileSass(file string) (sassResult, error) {
+	if c := sassEmbedded(); c != nil {
+		if result, err := c.compile(file); !errors.Is(err, errSassUnavailable) {
+			return result, err
+		}
+	}
+
+	sass := Exec("sass", file, "-I", filepath.Dir(file), "--embed-source-map", "--source-map-urls=absolute")
+	// if cwd is the path of the currently loaded file sass warns about it
+	// in order to avoid the deprecation warning we explicitly set it to something else
+	sass.Dir = "/"
+	sass.Stderr = os.Stderr
+
+	data, err := sass.Output()
+	if err != nil {
+		return sassResult{}, err
+	}
+
+	css, sourcemap := splitCssSourceMap(strings.TrimSpace(string(data)))
+	return sassResult{Css: css, SourceMap: sourcemap, Files: []string{file}}, nil
+}
@@ -0,0 +1,245 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type sourceMapping struct {
+	GenCol int
+	Source int
+	Line   int
+	Col    int
+	HasSrc bool
+}
+
+// SourceMap is a decoded source map of a bundle
+// https://sourcemaps.info/spec.html
+type SourceMap struct {
+	Sources []string
+	lines   [][]sourceMapping
+}
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodes the base64 VLQ values of a segment
+func decodeVLQ(segment string) ([]int, error) {
+	var values []int
+	value, shift := 0, 0
+
+	for _, c := range segment {
+		digit := strings.IndexRune(base64Chars, c)
+		if digit == -1 {
+			return nil, errors.New("invalid character in source map: " + string(c))
+		}
+
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+
+		if value&1 != 0 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+
+	if shift != 0 {
+		return nil, errors.New("unterminated value in source map: " + segment)
+	}
+	return values, nil
+}
+
+func ParseSourceMap(data []byte) (*SourceMap, error) {
+	var raw struct {
+		Sources  []string `json:"sources"`
+		Mappings string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	m := &SourceMap{Sources: raw.Sources}
+	source, line, col := 0, 0, 0
+
+	for _, l := range strings.Split(raw.Mappings, ";") {
+		var mappings []sourceMapping
+		genCol := 0
+
+		for _, segment := range strings.Split(l, ",") {
+			if segment == "" {
+				continue
+			}
+
+			values, err := decodeVLQ(segment)
+			if err != nil {
+				return nil, err
+			}
+
+			genCol += values[0]
+			mapping := sourceMapping{GenCol: genCol}
+			if len(values) >= 4 {
+				source += values[1]
+				line += values[2]
+				col += values[3]
+				mapping.HasSrc = true
+				mapping.Source, mapping.Line, mapping.Col = source, line, col
+			}
+			mappings = append(mappings, mapping)
+		}
+
+		m.lines = append(m.lines, mappings)
+	}
+
+	return m, nil
+}
+
+// InlineSourceMap extracts the source map embedded into a bundle with a data url
+func InlineSourceMap(js []byte) (*SourceMap, error) {
+	prefix := []byte("//# sourceMappingURL=data:application/json;base64,")
+	i := bytes.LastIndex(js, prefix)
+	if i == -1 {
+		return nil, errors.New("no inline source map")
+	}
+
+	encoded := js[i+len(prefix):]
+	if end := bytes.IndexByte(encoded, '\n'); end != -1 {
+		encoded = encoded[:end]
+	}
+
+	data, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSourceMap(data)
+}
+
+// Lookup maps a 1-based line and column of the bundle to the original source,
+// the returned line and column are 1-based as well
+func (m *SourceMap) Lookup(line, col int) (string, int, int, bool) {
+	if line < 1 || line > len(m.lines) {
+		return "", 0, 0, false
+	}
+
+	mappings := m.lines[line-1]
+	i := sort.Search(len(mappings), func(i int) bool {
+		return mappings[i].GenCol > col-1
+	}) - 1
+
+	// the frame can point before the first segment, e.g the indentation
+	if i < 0 {
+		i = 0
+	}
+
+	if i >= len(mappings) || !mappings[i].HasSrc || mappings[i].Source >= len(m.Sources) {
+		return "", 0, 0, false
+	}
+
+	mapping := mappings[i]
+	return m.Sources[mapping.Source], mapping.Line + 1, mapping.Col + 1, true
+}
+
+// SourceMapWriter rewrites locations of bundled files in the lines written to it
+// to the original source locations, sources are resolved relative to their bundle
+// and printed relative to root when they are inside of it
+type SourceMapWriter struct {
+	w       io.Writer
+	maps    map[string]*SourceMap
+	root    string
+	pattern *regexp.Regexp
+	buf     []byte
+}
+
+// NewSourceMapWriter takes the source maps keyed by the path of their bundle
+func NewSourceMapWriter(w io.Writer, maps map[string]*SourceMap, root string) *SourceMapWriter {
+	bundles := make([]string, 0, len(maps))
+	for bundle := range maps {
+		bundles = append(bundles, regexp.QuoteMeta(bundle))
+	}
+
+	return &SourceMapWriter{
+		w:       w,
+		maps:    maps,
+		root:    root,
+		pattern: regexp.MustCompile(`(?:file://)?(` + strings.Join(bundles, "|") + `):(\d+):(\d+)`),
+	}
+}
+
+// InlineSourceMaps reads the inline source maps of the given bundles
+// skipping the ones which do not have one
+func InlineSourceMaps(bundles ...string) map[string]*SourceMap {
+	maps := map[string]*SourceMap{}
+	for _, bundle := range bundles {
+		if m, err := InlineSourceMap(ReadFile(bundle)); err == nil {
+			maps[bundle] = m
+		}
+	}
+	return maps
+}
+
+func (s *SourceMapWriter) source(bundle, source string) string {
+	path := filepath.Join(filepath.Dir(bundle), source)
+	if rel, err := filepath.Rel(s.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func (s *SourceMapWriter) rewrite(line []byte) []byte {
+	if len(s.maps) == 0 {
+		return line
+	}
+
+	return s.pattern.ReplaceAllFunc(line, func(match []byte) []byte {
+		groups := s.pattern.FindSubmatch(match)
+		bundle := string(groups[1])
+		l, _ := strconv.Atoi(string(groups[2]))
+		c, _ := strconv.Atoi(string(groups[3]))
+
+		source, line, col, ok := s.maps[bundle].Lookup(l, c)
+		if !ok {
+			return match
+		}
+
+		return []byte(s.source(bundle, source) + ":" + strconv.Itoa(line) + ":" + strconv.Itoa(col))
+	})
+}
+
+// Write rewrites complete lines, an incomplete line is held back until it ends or Flush is called
+func (s *SourceMapWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+
+	end := bytes.LastIndexByte(s.buf, '\n')
+	if end == -1 {
+		return len(p), nil
+	}
+
+	if _, err := s.w.Write(s.rewrite(s.buf[:end+1])); err != nil {
+		return 0, err
+	}
+
+	s.buf = append(s.buf[:0], s.buf[end+1:]...)
+	return len(p), nil
+}
+
+func (s *SourceMapWriter) Flush() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+
+	_, err := s.w.Write(s.rewrite(s.buf))
+	s.buf = s.buf[:0]
+	return err
+}
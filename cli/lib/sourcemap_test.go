@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDecodeVLQ(t *testing.T) {
+	tests := []struct {
+		segment string
+		want    []int
+		err     string
+	}{
+		{segment: "A", want: []int{0}},
+		{segment: "C", want: []int{1}},
+		{segment: "D", want: []int{-1}},
+		{segment: "AAAA", want: []int{0, 0, 0, 0}},
+		{segment: "SAAQ", want: []int{9, 0, 0, 8}},
+		{segment: "gB", want: []int{16}},
+		{segment: "hB", want: []int{-16}},
+		{segment: "2H", want: []int{123}},
+		{segment: "gggB", want: []int{1 << 14}},
+		{segment: "EgBD", want: []int{2, 16, -1}},
+		{segment: "A!", err: "invalid character"},
+		{segment: "Ag", err: "unterminated value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.segment, func(t *testing.T) {
+			got, err := decodeVLQ(test.segment)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// line 1 maps columns 0, 2 and 6 to line 0 of the source,
+// line 2 is empty, line 3 maps column 0 to line 1,
+// line 4 ends in a segment without a source and line 5 starts at column 4
+const testMappings = `{"sources": ["../src/app.ts"], "mappings": "AAAA,EAAE,IAAI;;AACA;AACA,K;IAAA"}`
+
+func TestSourceMapLookup(t *testing.T) {
+	m, err := ParseSourceMap([]byte(testMappings))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tests := []struct {
+		line, col int
+		want      string // source:line:col, empty if nothing is mapped
+	}{
+		{1, 1, "../src/app.ts:1:1"},
+		{1, 2, "../src/app.ts:1:1"},
+		{1, 3, "../src/app.ts:1:3"},
+		{1, 6, "../src/app.ts:1:3"},
+		{1, 7, "../src/app.ts:1:7"},
+		{1, 100, "../src/app.ts:1:7"},
+		{2, 1, ""},
+		{3, 1, "../src/app.ts:2:7"},
+		{4, 5, "../src/app.ts:3:7"},
+		{4, 6, ""},
+		{5, 1, "../src/app.ts:3:7"},
+		{0, 1, ""},
+		{6, 1, ""},
+	}
+
+	for _, test := range tests {
+		source, line, col, ok := m.Lookup(test.line, test.col)
+		got := ""
+		if ok {
+			got = source + ":" + strconv.Itoa(line) + ":" + strconv.Itoa(col)
+		}
+		if got != test.want {
+			t.Errorf("Lookup(%d, %d) = %q, want %q", test.line, test.col, got, test.want)
+		}
+	}
+
+	for _, mappings := range []string{`{"mappings": "Ag"}`, `{"mappings": "A!"}`, `{`} {
+		if _, err := ParseSourceMap([]byte(mappings)); err == nil {
+			t.Errorf("expected an error for %s", mappings)
+		}
+	}
+}
+
+func TestSourceMapWriter(t *testing.T) {
+	m, err := ParseSourceMap([]byte(testMappings))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var out bytes.Buffer
+	w := NewSourceMapWriter(&out, map[string]*SourceMap{"/p/dist/app.js": m}, "/p")
+
+	// a location split across two writes is rewritten once its line is complete
+	w.Write([]byte("at /p/dist/app.js:1:"))
+	if out.Len() != 0 {
+		t.Fatalf("incomplete line was written: %q", out.String())
+	}
+	w.Write([]byte("3 (x)\nat file:///p/dist/app.js:3:1\nat /p/dist/app.js:2:1\nat /p/dist/other.js:1:1\nat /p/dist/app.js:1:1"))
+
+	want := "at src/app.ts:1:3 (x)\nat src/app.ts:2:7\nat /p/dist/app.js:2:1\nat /p/dist/other.js:1:1\n"
+	if out.String() != want {
+		t.Fatalf("got\n%q\nwant\n%q", out.String(), want)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want += "at src/app.ts:1:1"
+	if out.String() != want {
+		t.Fatalf("got\n%q\nwant\n%q", out.String(), want)
+	}
+}
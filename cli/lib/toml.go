@@ -0,0 +1,481 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dataError is a syntax error of a data file
+type dataError struct {
+	Line int
+	Msg  string
+}
+
+func (e *dataError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// tomlParser is a parser for the subset of TOML which can be represented as JSON
+// https://toml.io/en/v1.0.0
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+
+	// tables defined with a header, which can not be defined again
+	defined map[string]bool
+}
+
+var tomlDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}|^\d{2}:\d{2}:\d{2}`)
+
+func parseToml(src string) (map[string]any, error) {
+	p := &tomlParser{src: src, line: 1}
+	root := map[string]any{}
+
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(*dataError); ok {
+					err = e
+					return
+				}
+				panic(r)
+			}
+		}()
+		p.document(root)
+	}()
+
+	return root, err
+}
+
+func (p *tomlParser) fail(format string, args ...any) {
+	panic(&dataError{p.line, fmt.Sprintf(format, args...)})
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) next() byte {
+	c := p.peek()
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *tomlParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail("expected %q", c)
+	}
+	p.next()
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.next()
+		}
+	}
+}
+
+// skips whitespace, newlines and comments
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if c := p.peek(); c != '\n' && c != '\r' {
+			return
+		}
+		p.next()
+	}
+}
+
+func (p *tomlParser) endOfLine() {
+	p.skipSpace()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.next()
+	}
+	if !p.eof() && p.peek() != '\n' {
+		p.fail("expected the end of the line")
+	}
+}
+
+func (p *tomlParser) document(root map[string]any) {
+	p.defined = map[string]bool{}
+	table := root
+
+	for p.skipBlank(); !p.eof(); p.skipBlank() {
+		if p.peek() == '[' {
+			table = p.header(root)
+		} else {
+			p.keyValue(table)
+		}
+		p.endOfLine()
+	}
+}
+
+// parses a [table] or [[array]] header and returns the table it opens
+func (p *tomlParser) header(root map[string]any) map[string]any {
+	p.expect('[')
+	array := p.peek() == '['
+	if array {
+		p.next()
+	}
+
+	p.skipSpace()
+	keys := p.key()
+	p.skipSpace()
+	p.expect(']')
+	if array {
+		p.expect(']')
+	}
+
+	table := root
+	for _, key := range keys[:len(keys)-1] {
+		table = p.subtable(table, key)
+	}
+
+	last := keys[len(keys)-1]
+	if array {
+		existing, ok := table[last]
+		if !ok {
+			existing = []any{}
+		}
+		tables, ok := existing.([]any)
+		if !ok {
+			p.fail("%s is not an array of tables", last)
+		}
+		next := map[string]any{}
+		table[last] = append(tables, next)
+		return next
+	}
+
+	next := p.subtable(table, last)
+	id := fmt.Sprintf("%p", next)
+	if p.defined[id] {
+		p.fail("table %s is defined more than once", strings.Join(keys, "."))
+	}
+	p.defined[id] = true
+	return next
+}
+
+// returns the table under key, an array of tables resolves to its last element
+func (p *tomlParser) subtable(table map[string]any, key string) map[string]any {
+	switch v := table[key].(type) {
+	case nil:
+		next := map[string]any{}
+		table[key] = next
+		return next
+	case map[string]any:
+		return v
+	case []any:
+		if len(v) > 0 {
+			if last, ok := v[len(v)-1].(map[string]any); ok {
+				return last
+			}
+		}
+	}
+	p.fail("%s is not a table", key)
+	return nil
+}
+
+func (p *tomlParser) keyValue(table map[string]any) {
+	keys := p.key()
+	p.skipSpace()
+	p.expect('=')
+	p.skipSpace()
+	value := p.value()
+
+	for _, key := range keys[:len(keys)-1] {
+		table = p.subtable(table, key)
+	}
+
+	last := keys[len(keys)-1]
+	if _, ok := table[last]; ok {
+		p.fail("key %s is defined more than once", strings.Join(keys, "."))
+	}
+	table[last] = value
+}
+
+// parses a dotted key
+func (p *tomlParser) key() []string {
+	var keys []string
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case '"':
+			keys = append(keys, p.basicString())
+		case '\'':
+			keys = append(keys, p.literalString())
+		default:
+			start := p.pos
+			for c := p.peek(); isBareKeyChar(c); c = p.peek() {
+				p.next()
+			}
+			if start == p.pos {
+				p.fail("expected a key")
+			}
+			keys = append(keys, p.src[start:p.pos])
+		}
+
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys
+		}
+		p.next()
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) value() any {
+	switch c := p.peek(); {
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		return p.multilineString('"')
+	case strings.HasPrefix(p.src[p.pos:], `'''`):
+		return p.multilineString('\'')
+	case c == '"':
+		return p.basicString()
+	case c == '\'':
+		return p.literalString()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	default:
+		return p.scalar()
+	}
+}
+
+func (p *tomlParser) array() []any {
+	p.expect('[')
+	values := []any{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.next()
+			return values
+		}
+
+		values = append(values, p.value())
+		p.skipBlank()
+
+		switch p.next() {
+		case ',':
+		case ']':
+			return values
+		default:
+			p.fail("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() map[string]any {
+	p.expect('{')
+	table := map[string]any{}
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.next()
+		return table
+	}
+
+	for {
+		p.keyValue(table)
+		p.skipSpace()
+		switch p.next() {
+		case ',':
+			p.skipSpace()
+		case '}':
+			return table
+		default:
+			p.fail("expected , or } in inline table")
+		}
+	}
+}
+
+func (p *tomlParser) escape() string {
+	switch c := p.next(); c {
+	case 'b':
+		return "\b"
+	case 't':
+		return "\t"
+	case 'n':
+		return "\n"
+	case 'f':
+		return "\f"
+	case 'r':
+		return "\r"
+	case 'e':
+		return "\x1b"
+	case '"', '\\':
+		return string(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			p.fail("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			p.fail("invalid unicode escape")
+		}
+		p.pos += size
+		return string(rune(code))
+	default:
+		p.fail("invalid escape \\%c", c)
+		return ""
+	}
+}
+
+func (p *tomlParser) basicString() string {
+	p.expect('"')
+	var b strings.Builder
+	for {
+		if c := p.peek(); c == '\n' || p.eof() {
+			p.fail("unterminated string")
+		}
+
+		switch c := p.next(); c {
+		case '"':
+			return b.String()
+		case '\\':
+			b.WriteString(p.escape())
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *tomlParser) literalString() string {
+	p.expect('\'')
+	start := p.pos
+	for {
+		if c := p.peek(); c == '\n' || p.eof() {
+			p.fail("unterminated string")
+		}
+
+		if p.next() == '\'' {
+			return p.src[start : p.pos-1]
+		}
+	}
+}
+
+func (p *tomlParser) multilineString(quote byte) string {
+	delim := strings.Repeat(string(quote), 3)
+	p.pos += 3
+
+	// a newline right after the delimiter is trimmed
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.next()
+	}
+	if p.peek() == '\n' {
+		p.next()
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			p.fail("unterminated string")
+		}
+
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += 3
+			// up to two quotes are allowed right before the closing delimiter
+			for i := 0; i < 2 && p.peek() == quote; i++ {
+				b.WriteByte(p.next())
+			}
+			return b.String()
+		}
+
+		c := p.next()
+		if c != '\\' || quote == '\'' {
+			b.WriteByte(c)
+			continue
+		}
+
+		// a line ending backslash trims the following whitespace
+		rest := strings.TrimLeft(p.src[p.pos:], " \t")
+		if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+			for c := p.peek(); c == ' ' || c == '\t' || c == '\n' || c == '\r'; c = p.peek() {
+				p.next()
+			}
+			continue
+		}
+
+		b.WriteString(p.escape())
+	}
+}
+
+func (p *tomlParser) scalar() any {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+		p.next()
+	}
+	token := p.src[start:p.pos]
+
+	// local date-time with a space instead of T
+	if tomlDate.MatchString(token) && p.peek() == ' ' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) {
+		p.next()
+		for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+			p.next()
+		}
+		token = p.src[start:p.pos]
+	}
+
+	switch {
+	case token == "":
+		p.fail("expected a value")
+	case token == "true":
+		return true
+	case token == "false":
+		return false
+	case tomlDate.MatchString(token):
+		// dates have no JSON representation
+		return token
+	case strings.HasSuffix(token, "inf") || strings.HasSuffix(token, "nan"):
+		p.fail("%s can not be represented in JSON", token)
+	}
+
+	number := strings.ReplaceAll(token, "_", "")
+	if strings.HasPrefix(number, "0x") || strings.HasPrefix(number, "0o") || strings.HasPrefix(number, "0b") {
+		if n, err := strconv.ParseInt(number, 0, 64); err == nil {
+			return n
+		}
+	} else if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return n
+	} else if f, err := strconv.ParseFloat(number, 64); err == nil && !math.IsInf(f, 0) {
+		return f
+	}
+
+	p.fail("invalid value %s", token)
+	return nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
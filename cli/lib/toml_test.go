@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseToml(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // JSON of the value
+		err  string // substring of the error
+	}{
+		{name: "empty", src: "", want: `{}`},
+		{name: "key values", src: "a = 1\nb = \"two\"\nc = true\n", want: `{"a":1,"b":"two","c":true}`},
+		{name: "tables", src: "[a]\nx = 1\n[a.b]\ny = 2\n", want: `{"a":{"b":{"y":2},"x":1}}`},
+		{name: "dotted keys", src: "a.b.c = 1\n\"d.e\" = 2\n", want: `{"a":{"b":{"c":1}},"d.e":2}`},
+		{name: "array of tables", src: "[[a]]\nx = 1\n[[a]]\nx = 2\n", want: `{"a":[{"x":1},{"x":2}]}`},
+		{name: "subtable of array", src: "[[a]]\n[a.b]\nx = 1\n", want: `{"a":[{"b":{"x":1}}]}`},
+		{name: "inline table", src: "a = { x = 1, y.z = 'w' }\n", want: `{"a":{"x":1,"y":{"z":"w"}}}`},
+		{name: "arrays", src: "a = [\n  1, # one\n  2,\n]\n", want: `{"a":[1,2]}`},
+		{name: "comments", src: "# c\na = 1 # c\nb = \"# not\"\n", want: `{"a":1,"b":"# not"}`},
+		{name: "escapes", src: `a = "x\ty\u00e9\""` + "\n", want: `{"a":"x\tyé\""}`},
+		{name: "literal string", src: `a = 'C:\path'` + "\n", want: `{"a":"C:\\path"}`},
+		{name: "multiline string", src: "a = \"\"\"\nx \\\n  y\"\"\"\n", want: `{"a":"x y"}`},
+		{name: "multiline literal", src: "a = '''\nx\\n'''\n", want: `{"a":"x\\n"}`},
+		{name: "quoted empty key", src: "\"\" = 1\n", want: `{"":1}`},
+		{name: "numbers", src: "a = 1_000\nb = 0xff\nc = 0o7\nd = 0b11\ne = 1.5e2\nf = -0.5\n", want: `{"a":1000,"b":255,"c":7,"d":3,"e":150,"f":-0.5}`},
+		{name: "offset datetime", src: "a = 1979-05-27T07:32:00Z\n", want: `{"a":"1979-05-27T07:32:00Z"}`},
+		{name: "offset datetime with fraction", src: "a = 1979-05-27T00:32:00.999999-07:00\n", want: `{"a":"1979-05-27T00:32:00.999999-07:00"}`},
+		{name: "local datetime with space", src: "a = 1979-05-27 07:32:00\n", want: `{"a":"1979-05-27 07:32:00"}`},
+		{name: "local date", src: "a = 1979-05-27\n", want: `{"a":"1979-05-27"}`},
+		{name: "local time", src: "a = 07:32:00\n", want: `{"a":"07:32:00"}`},
+		{name: "date in array", src: "a = [1979-05-27, 1979-05-28]\n", want: `{"a":["1979-05-27","1979-05-28"]}`},
+
+		{name: "empty key", src: "= 1\n", err: "line 1: expected a key"},
+		{name: "empty dotted key", src: "a. = 1\n", err: "expected a key"},
+		{name: "empty header", src: "[]\n", err: "expected a key"},
+		{name: "inf", src: "a = inf\n", err: "inf can not be represented in JSON"},
+		{name: "signed inf", src: "a = -inf\n", err: "-inf can not be represented in JSON"},
+		{name: "nan", src: "a = [+nan]\n", err: "+nan can not be represented in JSON"},
+		{name: "duplicate key", src: "a = 1\na = 2\n", err: "line 2: key a is defined more than once"},
+		{name: "duplicate table", src: "[a]\n[a]\n", err: "table a is defined more than once"},
+		{name: "missing value", src: "a =\n", err: "expected a value"},
+		{name: "invalid value", src: "a = yes\n", err: "invalid value yes"},
+		{name: "unterminated string", src: "a = \"x\n", err: "unterminated string"},
+		{name: "trailing content", src: "a = 1 2\n", err: "expected the end of the line"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := parseToml(test.src)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			got, _ := json.Marshal(value)
+			if string(got) != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
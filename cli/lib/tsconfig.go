@@ -2,16 +2,16 @@ package lib
 
 import (
 	"encoding/json"
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/titanous/json5"
 )
 
 func defaultTsconfig(gtkVersion uint) map[string]any {
-	gtk := "gtk4"
-	if gtkVersion == 3 {
-		gtk = "gtk3"
-	}
-
 	return map[string]any{
 		"compilerOptions": map[string]any{
 			"module":           "ES2022",
@@ -19,36 +19,43 @@ func defaultTsconfig(gtkVersion uint) map[string]any {
 			"strict":           true,
 			"moduleResolution": "Bundler",
 			"jsx":              "react-jsx",
-			"jsxImportSource":  "ags/" + gtk,
+			"jsxImportSource":  jsxImportSource(gtkVersion),
 		},
 	}
 }
 
+// compiler options which are always set, jsxImportSource is only set if it is missing
+var tsconfigOptions = [][2]string{
+	{"module", "ES2022"},
+	{"target", "ES2020"},
+	{"moduleResolution", "Bundler"},
+	{"jsx", "react-jsx"},
+}
+
+func jsxImportSource(gtkVersion uint) string {
+	if gtkVersion == 3 {
+		return "ags/gtk3"
+	}
+	return "ags/gtk4"
+}
+
 // if jsxImportSource is not present update it to `gtkVersion`
 func updateTsconfig(tsconfig map[string]any, gtkVersion uint) {
 	compilerOptions, ok := tsconfig["compilerOptions"].(map[string]any)
 	if !ok {
 		compilerOptions = map[string]any{}
+		tsconfig["compilerOptions"] = compilerOptions
 	}
 
-	jsxImportSource, ok := compilerOptions["jsxImportSource"].(string)
-	if !ok {
-		jsxImportSource = "ags/gtk4"
-		if gtkVersion == 3 {
-			jsxImportSource = "ags/gtk3"
-		}
+	if _, ok := compilerOptions["jsxImportSource"].(string); !ok {
+		compilerOptions["jsxImportSource"] = jsxImportSource(gtkVersion)
+	}
+	for _, o := range tsconfigOptions {
+		compilerOptions[o[0]] = o[1]
 	}
-
-	compilerOptions["module"] = "ES2022"
-	compilerOptions["target"] = "ES2020"
-	compilerOptions["moduleResolution"] = "Bundler"
-	compilerOptions["jsx"] = "react-jsx"
-	compilerOptions["jsxImportSource"] = jsxImportSource
 }
 
-// GetTsconfig if tsconfig.json exists in srcdir returns an updated config
-// otherwise returns a default config
-func GetTsconfig(srcdir string, gtkVersion uint) string {
+func loadTsconfig(srcdir string, gtkVersion uint) map[string]any {
 	// TODO: look in parent directories recursively
 	path := srcdir + "/tsconfig.json"
 
@@ -63,6 +70,10 @@ func GetTsconfig(srcdir string, gtkVersion uint) string {
 		tsconfig = defaultTsconfig(gtkVersion)
 	}
 
+	return tsconfig
+}
+
+func marshalTsconfig(tsconfig map[string]any) string {
 	conf, err := json.MarshalIndent(tsconfig, "", "  ")
 	if err != nil {
 		Err(err)
@@ -70,3 +81,165 @@ func GetTsconfig(srcdir string, gtkVersion uint) string {
 
 	return string(conf)
 }
+
+// GetTsconfig if tsconfig.json exists in srcdir returns an updated config
+// otherwise returns a default config
+func GetTsconfig(srcdir string, gtkVersion uint) string {
+	return marshalTsconfig(loadTsconfig(srcdir, gtkVersion))
+}
+
+// aliasPath is the target of an alias as a path relative to the tsconfig,
+// aliased packages are looked up in node_modules
+func aliasPath(target string) string {
+	if filepath.IsAbs(target) || strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") {
+		return target
+	}
+	return "./node_modules/" + target
+}
+
+// marks the paths of tsconfig.json which mirror an alias
+const tsconfigPathMarker = "/* ags sync */"
+
+// the same value, ignoring formatting
+func sameJson(a, b []byte) bool {
+	var x, y any
+	if json5.Unmarshal(a, &x) != nil || json5.Unmarshal(b, &y) != nil {
+		return false
+	}
+	xs, _ := json.Marshal(x)
+	ys, _ := json.Marshal(y)
+	return string(xs) == string(ys)
+}
+
+// SyncTsconfigPaths mirrors the aliases into compilerOptions.paths of tsconfig,
+// so that editors resolve the aliased modules the same way the bundler does.
+// The entries are marked, so that they are removed once their alias is gone,
+// and tsconfig is edited in place to keep its formatting and comments.
+// Returns whether tsconfig was changed
+func SyncTsconfigPaths(tsconfig []byte, alias map[string]string) ([]byte, bool, error) {
+	want := map[string][]byte{}
+	for name, target := range alias {
+		dir, _ := json.Marshal([]string{aliasPath(target)})
+		files, _ := json.Marshal([]string{aliasPath(target) + "/*"})
+		want[name] = dir
+		want[name+"/*"] = files
+	}
+
+	root, err := scanJson(tsconfig)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed := false
+	if paths := root.find("compilerOptions", "paths"); paths != nil {
+		remove := map[string]bool{}
+		for _, m := range paths.members {
+			value, wanted := want[m.key]
+			owned := strings.TrimSpace(string(tsconfig[m.leading:m.start])) == tsconfigPathMarker
+			if owned && !wanted || wanted && !sameJson(tsconfig[m.value:m.end], value) {
+				remove[m.key] = true
+			}
+		}
+
+		edits := removeMembers(tsconfig, paths, remove)
+		// paths which only had mirrored entries are removed entirely
+		if len(remove) == len(paths.members) && len(want) == 0 {
+			edits = removeMembers(tsconfig, root.find("compilerOptions"), map[string]bool{"paths": true})
+		}
+
+		if len(remove) > 0 {
+			tsconfig = applyJsonEdits(tsconfig, edits)
+			changed = true
+			if root, err = scanJson(tsconfig); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	paths := root.find("compilerOptions", "paths")
+
+	var entries []string
+	for _, key := range slices.Sorted(maps.Keys(want)) {
+		if paths != nil {
+			if _, ok := paths.member(key); ok {
+				continue
+			}
+		}
+		name, _ := json.Marshal(key)
+		entries = append(entries, tsconfigPathMarker+" "+string(name)+": "+string(want[key]))
+	}
+
+	if len(entries) == 0 {
+		return tsconfig, changed, nil
+	}
+
+	unit := indentUnit(tsconfig, root)
+	var edit jsonEdit
+	switch compilerOptions := root.find("compilerOptions"); {
+	case paths != nil:
+		edit = insertMembers(tsconfig, paths, memberIndent(tsconfig, paths, unit), entries)
+	case compilerOptions != nil:
+		indent := memberIndent(tsconfig, compilerOptions, unit)
+		edit = insertMembers(tsconfig, compilerOptions, indent,
+			[]string{`"paths": ` + objectText(indent, unit, entries)})
+	default:
+		indent := memberIndent(tsconfig, root, unit)
+		paths := `"paths": ` + objectText(indent+unit, unit, entries)
+		edit = insertMembers(tsconfig, root, indent,
+			[]string{`"compilerOptions": ` + objectText(indent, unit, []string{paths})})
+	}
+
+	return applyJsonEdits(tsconfig, []jsonEdit{edit}), true, nil
+}
+
+// UpdateTsconfig sets the compiler options of tsconfig like GetTsconfig does,
+// but edits tsconfig in place, so that its formatting, comments
+// and the markers of SyncTsconfigPaths are kept.
+// Returns whether tsconfig was changed
+func UpdateTsconfig(tsconfig []byte, gtkVersion uint) ([]byte, bool, error) {
+	root, err := scanJson(tsconfig)
+	if err != nil {
+		return nil, false, err
+	}
+
+	compilerOptions := root.find("compilerOptions")
+	if compilerOptions == nil {
+		if _, ok := root.member("compilerOptions"); ok {
+			return nil, false, errors.New("compilerOptions is not an object")
+		}
+	}
+
+	options := append(slices.Clone(tsconfigOptions), [2]string{"jsxImportSource", jsxImportSource(gtkVersion)})
+
+	var edits []jsonEdit
+	var entries []string
+	for _, o := range options {
+		name, _ := json.Marshal(o[0])
+		value, _ := json.Marshal(o[1])
+		if compilerOptions != nil {
+			if m, ok := compilerOptions.member(o[0]); ok {
+				if o[0] != "jsxImportSource" && !sameJson(tsconfig[m.value:m.end], value) {
+					edits = append(edits, jsonEdit{m.value, m.end, string(value)})
+				}
+				continue
+			}
+		}
+		entries = append(entries, string(name)+": "+string(value))
+	}
+
+	if len(entries) > 0 {
+		unit := indentUnit(tsconfig, root)
+		if compilerOptions != nil {
+			edits = append(edits, insertMembers(tsconfig, compilerOptions, memberIndent(tsconfig, compilerOptions, unit), entries))
+		} else {
+			indent := memberIndent(tsconfig, root, unit)
+			edits = append(edits, insertMembers(tsconfig, root, indent,
+				[]string{`"compilerOptions": ` + objectText(indent, unit, entries)}))
+		}
+	}
+
+	if len(edits) == 0 {
+		return tsconfig, false, nil
+	}
+	return applyJsonEdits(tsconfig, edits), true, nil
+}
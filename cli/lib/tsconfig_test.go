@@ -0,0 +1,174 @@
+package lib
+
+import "testing"
+
+func TestSyncTsconfigPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		tsconfig string
+		alias    map[string]string
+		want     string // empty if tsconfig is unchanged
+	}{
+		{
+			name:     "no aliases",
+			tsconfig: "{\n  // comment\n  \"compilerOptions\": { \"strict\": true }\n}\n",
+		},
+		{
+			name:     "adds paths",
+			tsconfig: "{\n  // comment\n  \"compilerOptions\": {\n    \"strict\": true // strict\n  }\n}\n",
+			alias:    map[string]string{"utils": "./src/utils"},
+			want: "{\n  // comment\n  \"compilerOptions\": {\n    \"strict\": true, // strict\n    \"paths\": {\n" +
+				"      /* ags sync */ \"utils\": [\"./src/utils\"],\n" +
+				"      /* ags sync */ \"utils/*\": [\"./src/utils/*\"]\n    }\n  }\n}\n",
+		},
+		{
+			name:     "adds compilerOptions",
+			tsconfig: "{\n    \"include\": [\"src\"]\n}",
+			alias:    map[string]string{"lodash": "lodash-es"},
+			want: "{\n    \"include\": [\"src\"],\n    \"compilerOptions\": {\n        \"paths\": {\n" +
+				"            /* ags sync */ \"lodash\": [\"./node_modules/lodash-es\"],\n" +
+				"            /* ags sync */ \"lodash/*\": [\"./node_modules/lodash-es/*\"]\n        }\n    }\n}",
+		},
+		{
+			name: "keeps synced and user paths",
+			tsconfig: "{\n  \"compilerOptions\": {\n    \"paths\": {\n      \"@/*\": [\"./src/*\"],\n" +
+				"      /* ags sync */ \"utils\": [\"./src/utils\"],\n" +
+				"      /* ags sync */ \"utils/*\": [\"./src/utils/*\"]\n    }\n  }\n}\n",
+			alias: map[string]string{"utils": "./src/utils"},
+		},
+		{
+			name: "removes stale paths",
+			tsconfig: "{\n  \"compilerOptions\": {\n    \"paths\": {\n      \"@/*\": [\"./src/*\"],\n" +
+				"      /* ags sync */ \"utils\": [\"./src/utils\"],\n" +
+				"      /* ags sync */ \"utils/*\": [\"./src/utils/*\"]\n    }\n  }\n}\n",
+			want: "{\n  \"compilerOptions\": {\n    \"paths\": {\n      \"@/*\": [\"./src/*\"]\n    }\n  }\n}\n",
+		},
+		{
+			name: "removes paths of stale aliases",
+			tsconfig: "{\n  \"compilerOptions\": {\n    \"strict\": true,\n    \"paths\": {\n" +
+				"      /* ags sync */ \"utils\": [\"./src/utils\"],\n" +
+				"      /* ags sync */ \"utils/*\": [\"./src/utils/*\"]\n    }\n  }\n}\n",
+			want: "{\n  \"compilerOptions\": {\n    \"strict\": true\n  }\n}\n",
+		},
+		{
+			name: "removes paths on a single line",
+			tsconfig: `{ "compilerOptions": { "strict": true, "paths": { /* ags sync */ "x": ["./x"], ` +
+				`/* ags sync */ "x/*": ["./x/*"], "y": ["./y"] } } }`,
+			want: `{ "compilerOptions": { "strict": true, "paths": { "y": ["./y"] } } }`,
+		},
+		{
+			name:     "removes single line paths entirely",
+			tsconfig: `{ "compilerOptions": { "strict": true, "paths": { /* ags sync */ "x": ["./x"] } } }`,
+			want:     `{ "compilerOptions": { "strict": true } }`,
+		},
+		{
+			name: "updates changed paths",
+			tsconfig: "{\n  \"compilerOptions\": {\n    \"paths\": {\n" +
+				"      /* ags sync */ \"utils\": [\"./src/utils\"],\n" +
+				"      /* ags sync */ \"utils/*\": [\"./src/utils/*\"],\n" +
+				"      \"@/*\": [\"./src/*\"],\n    },\n  },\n}\n",
+			alias: map[string]string{"utils": "./lib/utils"},
+			want: "{\n  \"compilerOptions\": {\n    \"paths\": {\n      \"@/*\": [\"./src/*\"],\n" +
+				"      /* ags sync */ \"utils\": [\"./lib/utils\"],\n" +
+				"      /* ags sync */ \"utils/*\": [\"./lib/utils/*\"],\n    },\n  },\n}\n",
+		},
+		{
+			name:     "user path with the same value",
+			tsconfig: "{ \"compilerOptions\": { \"paths\": { \"x\": [\"./x\"], \"x/*\": ['./x/*'] } } }",
+			alias:    map[string]string{"x": "./x"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, changed, err := SyncTsconfigPaths([]byte(test.tsconfig), test.alias)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			want := test.want
+			if want == "" {
+				want = test.tsconfig
+			}
+			if changed != (test.want != "") {
+				t.Errorf("got changed %v", changed)
+			}
+			if string(got) != want {
+				t.Fatalf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestUpdateTsconfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		tsconfig string
+		want     string // empty if tsconfig is unchanged
+	}{
+		{
+			name: "up to date",
+			tsconfig: "{\n  // comment\n  \"compilerOptions\": {\n    \"module\": \"ES2022\",\n    \"target\": \"ES2020\",\n" +
+				"    \"moduleResolution\": \"Bundler\",\n    \"jsx\": \"react-jsx\",\n    \"jsxImportSource\": \"ags/gtk3\"\n  }\n}\n",
+		},
+		{
+			name: "sets options and keeps jsxImportSource",
+			tsconfig: "{\n  \"compilerOptions\": {\n    \"target\": \"ES5\", // old\n    \"jsxImportSource\": \"ags/gtk3\",\n" +
+				"    \"strict\": true\n  }\n}\n",
+			want: "{\n  \"compilerOptions\": {\n    \"target\": \"ES2020\", // old\n    \"jsxImportSource\": \"ags/gtk3\",\n" +
+				"    \"strict\": true,\n    \"module\": \"ES2022\",\n    \"moduleResolution\": \"Bundler\",\n    \"jsx\": \"react-jsx\"\n  }\n}\n",
+		},
+		{
+			name:     "adds compilerOptions",
+			tsconfig: "{\n  \"include\": [\"src\"]\n}",
+			want: "{\n  \"include\": [\"src\"],\n  \"compilerOptions\": {\n    \"module\": \"ES2022\",\n    \"target\": \"ES2020\",\n" +
+				"    \"moduleResolution\": \"Bundler\",\n    \"jsx\": \"react-jsx\",\n    \"jsxImportSource\": \"ags/gtk4\"\n  }\n}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, changed, err := UpdateTsconfig([]byte(test.tsconfig), 0)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			want := test.want
+			if want == "" {
+				want = test.tsconfig
+			}
+			if changed != (test.want != "") {
+				t.Errorf("got changed %v", changed)
+			}
+			if string(got) != want {
+				t.Fatalf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+
+	if _, _, err := UpdateTsconfig([]byte(`{"compilerOptions": []}`), 0); err == nil {
+		t.Errorf("expected an error for compilerOptions which is not an object")
+	}
+}
+
+// types --update followed by a sync without the alias removes its paths
+func TestUpdateTsconfigKeepsSyncedPaths(t *testing.T) {
+	tsconfig := []byte("{\n  \"compilerOptions\": {\n    \"strict\": true\n  }\n}\n")
+
+	tsconfig, _, err := SyncTsconfigPaths(tsconfig, map[string]string{"utils": "./src/utils"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if tsconfig, _, err = UpdateTsconfig(tsconfig, 0); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if tsconfig, _, err = SyncTsconfigPaths(tsconfig, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := "{\n  \"compilerOptions\": {\n    \"strict\": true,\n    \"module\": \"ES2022\",\n    \"target\": \"ES2020\",\n" +
+		"    \"moduleResolution\": \"Bundler\",\n    \"jsx\": \"react-jsx\",\n    \"jsxImportSource\": \"ags/gtk4\"\n  }\n}\n"
+	if string(tsconfig) != want {
+		t.Fatalf("got\n%s\nwant\n%s", tsconfig, want)
+	}
+}
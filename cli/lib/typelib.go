@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// TypelibDirPatterns are the directories gjs loads .typelib files from, they can contain globs
+func TypelibDirPatterns() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv("GI_TYPELIB_PATH")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return append(dirs,
+		"/usr/local/lib*/girepository-1.0",
+		"/usr/lib*/girepository-1.0",
+		"/usr/lib/*/girepository-1.0",
+	)
+}
+
+// FindTypelibs returns every .typelib file in the search path keyed by its name
+func FindTypelibs() map[string]GirFile {
+	return findNamespaces(TypelibDirPatterns(), ".typelib")
+}
+
+// FindTypelib returns the typelib of a namespace, an empty version matches any version
+func FindTypelib(typelibs map[string]GirFile, namespace, version string) (GirFile, bool) {
+	if version != "" {
+		typelib, ok := typelibs[namespace+"-"+version]
+		return typelib, ok
+	}
+
+	versions := TypelibVersions(typelibs, namespace)
+	if len(versions) == 0 {
+		return GirFile{}, false
+	}
+	return typelibs[namespace+"-"+versions[len(versions)-1]], true
+}
+
+// TypelibVersions returns the sorted versions of a namespace
+func TypelibVersions(typelibs map[string]GirFile, namespace string) []string {
+	var versions []string
+	for _, typelib := range typelibs {
+		if typelib.Namespace == namespace {
+			versions = append(versions, typelib.Version)
+		}
+	}
+	slices.SortFunc(versions, CompareVersions)
+	return versions
+}
+
+// TypelibDependencies reads the names of namespaces a .typelib file depends on
+// from its header, see girepository/gitypelib-internal.h
+func TypelibDependencies(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) < 40 || !bytes.HasPrefix(data, []byte("GOBJ\nMETADATA\r\n\x1a")) {
+		return nil
+	}
+
+	offset := binary.LittleEndian.Uint32(data[36:40])
+	if offset == 0 || int(offset) >= len(data) {
+		return nil
+	}
+
+	end := bytes.IndexByte(data[offset:], 0)
+	if end <= 0 {
+		return nil
+	}
+
+	return strings.Split(string(data[offset:int(offset)+end]), "|")
+}
+
+// TypelibOwner returns the name of the package which installed a file
+// by asking the package manager of the system, or empty if not discoverable
+func TypelibOwner(path string) string {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		real = path
+	}
+
+	// /nix/store/<hash>-<name>/lib/girepository-1.0/X.typelib
+	if rest, ok := strings.CutPrefix(real, "/nix/store/"); ok {
+		dir, _, _ := strings.Cut(rest, "/")
+		if _, name, ok := strings.Cut(dir, "-"); ok {
+			return name
+		}
+	}
+
+	queries := [][]string{
+		{"pacman", "-Qoq", real},
+		{"dpkg-query", "-S", real},
+		{"rpm", "-qf", "--queryformat", "%{NAME}", real},
+		{"xbps-query", "-o", real},
+	}
+
+	for _, q := range queries {
+		if _, err := exec.LookPath(q[0]); err != nil {
+			continue
+		}
+
+		out, err := Exec(q[0], q[1:]...).Output()
+		if err != nil {
+			continue
+		}
+
+		owner := strings.TrimSpace(string(out))
+		// dpkg-query and xbps-query print "package: path"
+		owner, _, _ = strings.Cut(owner, ":")
+		if line, _, ok := strings.Cut(owner, "\n"); ok {
+			owner = line
+		}
+		return owner
+	}
+
+	return ""
+}
@@ -0,0 +1,560 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlParser is a parser for the block and flow styles of YAML
+// without anchors, aliases, tags and multiple documents
+// https://yaml.org/spec/1.2.2/
+type yamlParser struct {
+	raw   []string
+	lines []yamlLine
+	i     int
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// numbers of the core schema
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOct   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+func parseYaml(src string) (any, error) {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")}
+	for i, raw := range p.raw {
+		text := strings.TrimRight(stripYamlComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (text == trimmed && (trimmed == "---" || trimmed == "...")) {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "%") {
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{i + 1, len(text) - len(trimmed), trimmed})
+	}
+
+	var value any
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(*dataError); ok {
+					err = e
+					return
+				}
+				panic(r)
+			}
+		}()
+
+		if len(p.lines) == 0 {
+			return
+		}
+
+		value = p.node(p.lines[0].indent)
+		if p.i < len(p.lines) {
+			p.failAt(p.lines[p.i].num, "unexpected content")
+		}
+	}()
+
+	return value, err
+}
+
+// removes a comment which starts outside of quotes
+func stripYamlComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func (p *yamlParser) failAt(line int, format string, args ...any) {
+	panic(&dataError{line, fmt.Sprintf(format, args...)})
+}
+
+func (p *yamlParser) node(indent int) any {
+	line := p.lines[p.i]
+	switch {
+	case line.text == "-" || strings.HasPrefix(line.text, "- "):
+		return p.sequence(indent)
+	case line.text[0] != '[' && line.text[0] != '{' && yamlMappingColon(line.text) != -1:
+		return p.mapping(indent)
+	case line.text[0] == '|' || line.text[0] == '>':
+		p.i++
+		return p.blockScalar(line, line.text, line.indent-1)
+	default:
+		p.i++
+		return p.inline(line, line.text)
+	}
+}
+
+// a nested node which starts on the next line, or null when there is none
+func (p *yamlParser) child(parent int, sequenceAllowed bool) any {
+	if p.i >= len(p.lines) {
+		return nil
+	}
+
+	next := p.lines[p.i]
+	if next.indent > parent {
+		return p.node(next.indent)
+	}
+
+	// sequences can be on the same level as their key
+	if sequenceAllowed && next.indent == parent && (next.text == "-" || strings.HasPrefix(next.text, "- ")) {
+		return p.sequence(parent)
+	}
+
+	return nil
+}
+
+func (p *yamlParser) sequence(indent int) []any {
+	values := []any{}
+	for p.i < len(p.lines) {
+		line := p.lines[p.i]
+		if line.indent != indent || !(line.text == "-" || strings.HasPrefix(line.text, "- ")) {
+			break
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.i++
+			values = append(values, p.child(indent, false))
+			continue
+		}
+
+		// the content of the item continues as if it was on its own line
+		p.lines[p.i].indent += len(line.text) - len(rest)
+		p.lines[p.i].text = rest
+		values = append(values, p.node(p.lines[p.i].indent))
+	}
+	return values
+}
+
+func (p *yamlParser) mapping(indent int) map[string]any {
+	values := map[string]any{}
+	for p.i < len(p.lines) {
+		line := p.lines[p.i]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			p.failAt(line.num, "bad indentation")
+		}
+
+		colon := yamlMappingColon(line.text)
+		if line.text == "?" || strings.HasPrefix(line.text, "? ") {
+			p.failAt(line.num, "complex keys are not supported")
+		}
+		if colon == -1 {
+			p.failAt(line.num, "expected a key")
+		}
+
+		key := p.scalar(line, strings.TrimSpace(line.text[:colon]))
+		name := fmt.Sprint(key)
+		if key == nil {
+			name = "null"
+		}
+		if _, ok := values[name]; ok {
+			p.failAt(line.num, "duplicate key %s", name)
+		}
+
+		rest := strings.TrimSpace(line.text[colon+1:])
+		p.i++
+
+		switch {
+		case rest == "":
+			values[name] = p.child(indent, true)
+		case rest[0] == '|' || rest[0] == '>':
+			values[name] = p.blockScalar(line, rest, indent)
+		default:
+			values[name] = p.inline(line, rest)
+		}
+	}
+	return values
+}
+
+// index of the colon which separates a key from its value, or -1
+func yamlMappingColon(text string) int {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t'):
+			return i
+		}
+	}
+	return -1
+}
+
+// parses a literal (|) or folded (>) block scalar
+func (p *yamlParser) blockScalar(line yamlLine, header string, parent int) string {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	for _, c := range header[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomp = byte(c)
+		case c >= '1' && c <= '9':
+		default:
+			p.failAt(line.num, "invalid block scalar header %s", header)
+		}
+	}
+
+	end := p.i
+	for end < len(p.lines) && p.lines[end].indent > parent {
+		end++
+	}
+
+	// blank lines and comments were dropped, so the content is taken from the source
+	var lines []string
+	if end > p.i {
+		first, last := p.lines[p.i], p.lines[end-1]
+		for _, l := range p.raw[first.num-1 : last.num] {
+			l = strings.TrimRight(l, " \t\r")
+			if len(l) >= first.indent {
+				lines = append(lines, l[first.indent:])
+			} else {
+				lines = append(lines, strings.TrimLeft(l, " "))
+			}
+		}
+	}
+	p.i = end
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, l := range lines {
+			switch {
+			case i == 0:
+			case l == "" || lines[i-1] == "" || strings.HasPrefix(l, " "):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(l)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	switch chomp {
+	case '-':
+		return strings.TrimRight(text, "\n")
+	case '+':
+		return text + "\n"
+	default:
+		if text == "" {
+			return ""
+		}
+		return strings.TrimRight(text, "\n") + "\n"
+	}
+}
+
+// parses a value which starts on the current line,
+// flow collections and quoted strings can continue on the following lines
+func (p *yamlParser) inline(line yamlLine, text string) any {
+	if c := text[0]; c == '[' || c == '{' || c == '"' || c == '\'' {
+		for !yamlBalanced(text) && p.i < len(p.lines) {
+			text += " " + p.lines[p.i].text
+			p.i++
+		}
+
+		f := &yamlFlow{p: p, line: line, src: text}
+		value := f.value()
+		f.skipSpace()
+		if f.pos < len(f.src) {
+			p.failAt(line.num, "unexpected content after %s", text[:f.pos])
+		}
+		return value
+	}
+
+	// plain scalars can span multiple lines
+	for p.i < len(p.lines) && p.lines[p.i].indent > line.indent && yamlMappingColon(p.lines[p.i].text) == -1 {
+		text += " " + p.lines[p.i].text
+		p.i++
+	}
+
+	return p.scalar(line, text)
+}
+
+// whether quotes and brackets of a flow value are closed
+func yamlBalanced(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0 && quote == 0
+}
+
+func (p *yamlParser) scalar(line yamlLine, text string) any {
+	if text == "" {
+		p.failAt(line.num, "expected a key")
+	}
+	if text == "?" || strings.HasPrefix(text, "? ") {
+		p.failAt(line.num, "complex keys are not supported")
+	}
+
+	switch c := text[0]; c {
+	case '&', '*', '!':
+		p.failAt(line.num, "anchors, aliases and tags are not supported")
+	case '"', '\'':
+		f := &yamlFlow{p: p, line: line, src: text}
+		return f.quoted()
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "-.inf", "-.Inf", "-.INF", ".nan", ".NaN", ".NAN":
+		p.failAt(line.num, "%s can not be represented in JSON", text)
+	}
+
+	switch {
+	case yamlInt.MatchString(text):
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	case yamlOct.MatchString(text):
+		if n, err := strconv.ParseInt(text[2:], 8, 64); err == nil {
+			return n
+		}
+	case yamlHex.MatchString(text):
+		if n, err := strconv.ParseInt(text[2:], 16, 64); err == nil {
+			return n
+		}
+	case yamlFloat.MatchString(text):
+		if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(f, 0) {
+			return f
+		}
+	}
+
+	return text
+}
+
+// yamlFlow parses flow collections e.g [a, {b: c}] and quoted strings
+type yamlFlow struct {
+	p    *yamlParser
+	line yamlLine
+	src  string
+	pos  int
+}
+
+func (f *yamlFlow) fail(format string, args ...any) {
+	f.p.failAt(f.line.num, format, args...)
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.src) && (f.src[f.pos] == ' ' || f.src[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) peek() byte {
+	if f.pos >= len(f.src) {
+		return 0
+	}
+	return f.src[f.pos]
+}
+
+func (f *yamlFlow) value() any {
+	f.skipSpace()
+	switch f.peek() {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		return f.quoted()
+	default:
+		return f.plain()
+	}
+}
+
+func (f *yamlFlow) sequence() []any {
+	f.pos++
+	values := []any{}
+	for {
+		f.skipSpace()
+		if f.peek() == ']' {
+			f.pos++
+			return values
+		}
+
+		values = append(values, f.value())
+		f.skipSpace()
+
+		switch f.peek() {
+		case ',':
+			f.pos++
+		case ']':
+		default:
+			f.fail("expected , or ] in flow sequence")
+		}
+	}
+}
+
+func (f *yamlFlow) mapping() map[string]any {
+	f.pos++
+	values := map[string]any{}
+	for {
+		f.skipSpace()
+		if f.peek() == '}' {
+			f.pos++
+			return values
+		}
+
+		key := fmt.Sprint(f.value())
+		f.skipSpace()
+
+		var value any
+		if f.peek() == ':' {
+			f.pos++
+			value = f.value()
+			f.skipSpace()
+		}
+		values[key] = value
+
+		switch f.peek() {
+		case ',':
+			f.pos++
+		case '}':
+		default:
+			f.fail("expected , or } in flow mapping")
+		}
+	}
+}
+
+func (f *yamlFlow) plain() any {
+	start := f.pos
+	for f.pos < len(f.src) {
+		c := f.src[f.pos]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if c == ':' && (f.pos+1 == len(f.src) || strings.ContainsRune(" ,]}", rune(f.src[f.pos+1]))) {
+			break
+		}
+		f.pos++
+	}
+
+	text := strings.TrimSpace(f.src[start:f.pos])
+	if text == "" {
+		return nil
+	}
+	return f.p.scalar(f.line, text)
+}
+
+func (f *yamlFlow) quoted() string {
+	quote := f.src[f.pos]
+	f.pos++
+
+	var b strings.Builder
+	for {
+		if f.pos >= len(f.src) {
+			f.fail("unterminated string")
+		}
+
+		c := f.src[f.pos]
+		f.pos++
+
+		switch {
+		case c == quote && quote == '\'' && f.peek() == '\'':
+			f.pos++
+			b.WriteByte('\'')
+		case c == quote:
+			return b.String()
+		case c == '\\' && quote == '"':
+			b.WriteString(f.escape())
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (f *yamlFlow) escape() string {
+	if f.pos >= len(f.src) {
+		f.fail("unterminated string")
+	}
+
+	c := f.src[f.pos]
+	f.pos++
+
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if size > 0 {
+		if f.pos+size > len(f.src) {
+			f.fail("invalid escape")
+		}
+		code, err := strconv.ParseUint(f.src[f.pos:f.pos+size], 16, 32)
+		if err != nil {
+			f.fail("invalid escape")
+		}
+		f.pos += size
+		return string(rune(code))
+	}
+
+	escapes := map[byte]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+		'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': `"`,
+		'/': "/", '\\': `\`, 'N': "\u0085", '_': " ",
+	}
+	if s, ok := escapes[c]; ok {
+		return s
+	}
+
+	f.fail("invalid escape \\%c", c)
+	return ""
+}
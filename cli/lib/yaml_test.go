@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseYaml(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // JSON of the value
+		err  string // substring of the error
+	}{
+		{name: "empty", src: "", want: "null"},
+		{name: "mapping", src: "a: 1\nb: two\n", want: `{"a":1,"b":"two"}`},
+		{name: "nested mapping", src: "a:\n  b:\n    c: true\n", want: `{"a":{"b":{"c":true}}}`},
+		{name: "sequence", src: "- 1\n- a\n- null\n", want: `[1,"a",null]`},
+		{name: "sequence under key", src: "a:\n- 1\n- 2\n", want: `{"a":[1,2]}`},
+		{name: "mapping in sequence", src: "- a: 1\n  b: 2\n- c\n", want: `[{"a":1,"b":2},"c"]`},
+		{name: "flow", src: "a: [1, {b: c}, 'd']\n", want: `{"a":[1,{"b":"c"},"d"]}`},
+		{name: "flow over lines", src: "a: [1,\n  2]\n", want: `{"a":[1,2]}`},
+		{name: "comments", src: "# c\na: 1 # c\nb: '# not'\n", want: `{"a":1,"b":"# not"}`},
+		{name: "double quoted", src: `a: "x\ty\u00e9"` + "\n", want: `{"a":"x\tyé"}`},
+		{name: "single quoted", src: "a: 'it''s'\n", want: `{"a":"it's"}`},
+		{name: "literal block", src: "a: |\n  x\n  y\nb: 1\n", want: `{"a":"x\ny\n","b":1}`},
+		{name: "folded block", src: "a: >-\n  x\n  y\n", want: `{"a":"x y"}`},
+		{name: "plain multiline", src: "a: x\n  y\n", want: `{"a":"x y"}`},
+		{name: "null key", src: "null: 1\n", want: `{"null":1}`},
+		{name: "ints", src: "- 0o17\n- 0x1f\n- -3\n", want: `[15,31,-3]`},
+		{name: "floats", src: "- 1.5\n- 1e3\n- .5\n", want: `[1.5,1000,0.5]`},
+		{name: "bools", src: "- true\n- False\n- yes\n", want: `[true,false,"yes"]`},
+		{name: "date", src: "d: 2001-12-14\n", want: `{"d":"2001-12-14"}`},
+		{name: "datetime", src: "d: 2001-12-14t21:59:43.10-05:00\n", want: `{"d":"2001-12-14t21:59:43.10-05:00"}`},
+		{name: "spaced datetime", src: "d: 2001-12-14 21:59:43.10 -5\n", want: `{"d":"2001-12-14 21:59:43.10 -5"}`},
+		{name: "document markers", src: "---\na: 1\n...\n", want: `{"a":1}`},
+		{name: "question mark in plain scalar", src: "a: ?b\n", want: `{"a":"?b"}`},
+
+		{name: "empty key", src: ":\n", err: "line 1: expected a key"},
+		{name: "empty nested key", src: "a:\n  :\n", err: "line 2: expected a key"},
+		{name: "empty key with value", src: ": 1\n", err: "line 1: expected a key"},
+		{name: "complex key", src: "? a\n: b\n", err: "line 1: complex keys are not supported"},
+		{name: "nested complex key", src: "a: 1\n? b\n", err: "line 2: complex keys are not supported"},
+		{name: "complex key with colon", src: "? a: b\n", err: "complex keys are not supported"},
+		{name: "inf", src: "a: .inf\n", err: ".inf can not be represented in JSON"},
+		{name: "negative inf", src: "- -.Inf\n", err: "-.Inf can not be represented in JSON"},
+		{name: "nan", src: "a: [.nan]\n", err: ".nan can not be represented in JSON"},
+		{name: "anchor", src: "a: &x 1\n", err: "anchors, aliases and tags are not supported"},
+		{name: "duplicate key", src: "a: 1\na: 2\n", err: "line 2: duplicate key a"},
+		{name: "bad indentation", src: "a: 1\n  b: 2\n", err: "line 2:"},
+		{name: "unterminated string", src: "a: \"x\n", err: "unterminated string"},
+		{name: "invalid escape", src: `a: "\q"` + "\n", err: `invalid escape \q`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := parseYaml(test.src)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			got, _ := json.Marshal(value)
+			if string(got) != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
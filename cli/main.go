@@ -21,10 +21,11 @@ var (
 	gjs            = "/bin/gjs"
 	cat            = "/bin/cat"
 	base64         = "/bin/base64"
+	mkdir          = "/bin/mkdir"
 )
 
 func main() {
-	lib.Initialize(agsJsPackage)
+	lib.Initialize(agsJsPackage, bash)
 	cmd.Initialize(cmd.Env{
 		Version:        version,
 		Data:           data,
@@ -35,6 +36,7 @@ func main() {
 		Bash:           bash,
 		Cat:            cat,
 		Base64:         base64,
+		Mkdir:          mkdir,
 	})
 	cmd.Execute()
 }
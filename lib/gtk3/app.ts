@@ -32,7 +32,7 @@ interface AppSignals extends Gtk.Application.SignalSignatures {
 class App extends Gtk.Application {
     declare $signals: AppSignals
 
-    #instanceName = "ags"
+    #instanceName = GLib.getenv("AGS_INSTANCE_NAME") ?? "ags"
     #main?: (...argv: string[]) => void
     #requestHandlers = 0
     #dbusService: AppDBus
@@ -303,6 +303,10 @@ class App extends Gtk.Application {
         if (css) this.apply_css(css, false)
         if (icons) app.add_icons(icons)
 
+        // icon theme assembled by ags run and ags bundle
+        const iconsDir = GLib.getenv("AGS_ICONS_DIR")
+        if (iconsDir) app.add_icons(iconsDir)
+
         this.applicationId = "io.Astal." + this.instanceName
         setConsoleLogDomain(this.instanceName)
         this.runAsync(programArgs)
@@ -120,6 +120,7 @@ in
       "-X main.bash=${bash}/bin/bash"
       "-X main.cat=${coreutils}/bin/cat"
       "-X main.base64=${coreutils}/bin/base64"
+      "-X main.mkdir=${coreutils}/bin/mkdir"
     ];
 
     passthru = {